@@ -0,0 +1,146 @@
+package flow
+
+import (
+	"math"
+	"sort"
+)
+
+// AutoPositionedPad describes a pad which can have its side and offset
+// chosen by the layout, based on where the nodes it is connected to are.
+type AutoPositionedPad interface {
+	Pad
+	// AutoPositioned returns true if the layout should choose the side and
+	// offset of the pad.
+	AutoPositioned() bool
+	// SetPositioning is called by the layout to update the positioning of
+	// the pad, and should be reflected by Positioning() from then on. It is
+	// called from whichever goroutine is moving nodes, without the lock of
+	// the layout held, so implementations must be safe to call concurrently
+	// with Positioning().
+	SetPositioning(side NodeSide, sideAmt float64)
+}
+
+// sideFacing returns the side of a node of the given size which best faces
+// the given offset from its center.
+func sideFacing(dx, dy, w, h float64) NodeSide {
+	if math.Abs(dx)*h >= math.Abs(dy)*w {
+		if dx < 0 {
+			return SideLeft
+		}
+		return SideRight
+	}
+	if dy < 0 {
+		return SideTop
+	}
+	return SideBottom
+}
+
+// assignPadSides chooses the positioning of any automatically positioned
+// pads on the node. Each pad is placed on the side facing the average
+// position of the nodes it is connected to, and the pads on each side are
+// then spread evenly along it, ordered by the position of what they
+// connect to. Pads which are not connected keep their side.
+//
+// Pads with a fixed position take up the evenly spaced slot closest to
+// them, so automatically positioned pads are not placed on top of them.
+//
+// Changes to the positioning are recorded in the captured pads, and reported
+// to the pads by applyPositioning once the layout is unlocked.
+func (fl *Layout) assignPadSides(ni *nodeInfo) {
+	type placement struct {
+		pad   *padInfo
+		side  NodeSide
+		along float64
+	}
+	var (
+		placements []placement
+		fixed      [4][]float64
+		nl         = fl.node(ni)
+		w, h       = ni.w, ni.h
+	)
+
+	for _, pi := range ni.pads {
+		if pi.auto == nil {
+			fixed[pi.side] = append(fixed[pi.side], pi.sideAmt)
+			continue
+		}
+
+		var sumX, sumY, count float64
+		for _, ei := range append(pi.start, pi.end...) {
+			otherID := ei.toNodeID
+			if ei.toID == pi.id {
+				otherID = ei.fromNodeID
+			}
+			// Nodes which are not part of the layout have no position.
+			ol, ok := fl.nodes[otherID]
+			if !ok {
+				continue
+			}
+			sumX, sumY = sumX+ol.X, sumY+ol.Y
+			count++
+		}
+
+		pl := placement{pad: pi, side: pi.side, along: pi.sideAmt}
+		if count > 0 {
+			dx, dy := sumX/count-nl.X, sumY/count-nl.Y
+			pl.side = sideFacing(dx, dy, w, h)
+			if pl.side == SideLeft || pl.side == SideRight {
+				pl.along = dy / (h / 2)
+			} else {
+				pl.along = dx / (w / 2)
+			}
+		}
+		placements = append(placements, pl)
+	}
+
+	sort.SliceStable(placements, func(i, j int) bool {
+		if placements[i].side != placements[j].side {
+			return placements[i].side < placements[j].side
+		}
+		return placements[i].along < placements[j].along
+	})
+
+	for start := 0; start < len(placements); {
+		end := start
+		for end < len(placements) && placements[end].side == placements[start].side {
+			end++
+		}
+		slots := freeSlots(end-start, fixed[placements[start].side])
+		for i := start; i < end; i++ {
+			pi, side, sideAmt := placements[i].pad, placements[i].side, slots[i-start]
+			if side != pi.side || sideAmt != pi.sideAmt {
+				pi.side, pi.sideAmt = side, sideAmt
+				pi.placed = true
+			}
+		}
+		start = end
+	}
+}
+
+// freeSlots spreads n automatically positioned pads and the given fixed
+// pads evenly along a side, returning the positions left for the automatic
+// pads in order. Each fixed pad takes the slot closest to it.
+func freeSlots(n int, fixed []float64) []float64 {
+	var (
+		total = n + len(fixed)
+		taken = make([]bool, total)
+		slot  = func(i int) float64 { return -1 + 2*float64(i+1)/float64(total+1) }
+	)
+	for _, amt := range fixed {
+		best := -1
+		for i := range taken {
+			if !taken[i] && (best < 0 || math.Abs(slot(i)-amt) < math.Abs(slot(best)-amt)) {
+				best = i
+			}
+		}
+		taken[best] = true
+	}
+
+	out := make([]float64, 0, n)
+	for i := range taken {
+		if !taken[i] {
+			out = append(out, slot(i))
+		}
+	}
+	return out
+}
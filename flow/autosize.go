@@ -0,0 +1,180 @@
+package flow
+
+// Parameters used to compute the size of an AutoSizeNode. The headline
+// metrics mirror how flowui/render.BasicRenderer draws headlines.
+const (
+	HeadlineFontSize = 16
+
+	headlineInset   = 7
+	headlineHeight  = 26
+	autoPadSpacing  = 40
+	defaultMinW     = 100
+	defaultMinH     = 60
+	defaultMaxW     = 600
+	defaultMaxH     = 600
+	fallbackPadSize = 25
+)
+
+// AutoSizeNode is a node which sizes itself to fit its headline and the
+// number of pads on each side.
+//
+// When the node is attached to a layout, the layout is told to recompute
+// the position of the node's pads whenever the size of the node changes.
+// As the layout never calls into nodes while it is locked, the node may be
+// resized from within callbacks made by the layout.
+type AutoSizeNode struct {
+	headline string
+	id       string
+	pads     []Pad
+
+	layout  *Layout
+	measure TextMeasurer
+
+	minW, minH float64
+	maxW, maxH float64
+	w, h       float64
+}
+
+func (n *AutoSizeNode) NodeID() string {
+	return n.id
+}
+func (n *AutoSizeNode) Pads() []Pad {
+	return n.pads
+}
+func (n *AutoSizeNode) NodeHeadline() string {
+	return n.headline
+}
+
+// Size returns the size of the node, as computed when the headline, pads,
+// or size limits of the node last changed.
+func (n *AutoSizeNode) Size() (float64, float64) {
+	return n.w, n.h
+}
+
+// SetHeadline changes the headline of the node, resizing it if necessary.
+func (n *AutoSizeNode) SetHeadline(hl string) {
+	n.headline = hl
+	n.resize()
+}
+
+// SetSizeLimits sets the minimum and maximum size of the node. A maximum
+// of zero leaves that dimension unbounded.
+func (n *AutoSizeNode) SetSizeLimits(minW, minH, maxW, maxH float64) {
+	n.minW, n.minH, n.maxW, n.maxH = minW, minH, maxW, maxH
+	n.resize()
+}
+
+// SetMeasurer changes how the width of the headline is computed. A nil
+// measurer estimates the width with EstimateText.
+func (n *AutoSizeNode) SetMeasurer(m TextMeasurer) {
+	if m == nil {
+		m = EstimateText
+	}
+	n.measure = m
+	n.resize()
+}
+
+// Attach associates the node with a layout, which is told to recompute
+// the position of pads when the size of the node changes.
+func (n *AutoSizeNode) Attach(l *Layout) {
+	n.layout = l
+}
+
+func (n *AutoSizeNode) AppendSPad(t string, side NodeSide, sideAmt float64) {
+	n.AppendPad(NewSPad(t, n, side, sideAmt))
+}
+
+func (n *AutoSizeNode) AppendPad(pad Pad) {
+	n.pads = append(n.pads, pad)
+	n.resize()
+}
+
+// LinkPads implements flowui.UserLinkable.
+func (n *AutoSizeNode) LinkPads(toNode Node, fromPad, toPad Pad) (Edge, error) {
+	return linkPads(fromPad, toPad)
+}
+
+// fitSize computes the size needed to fit the headline and pads.
+func (n *AutoSizeNode) fitSize() (float64, float64) {
+	var (
+		textW, _           = n.measure(n.headline, HeadlineFontSize)
+		w, h               = textW + 2*headlineInset, float64(headlineHeight)
+		perSide            [4]int
+		padW, padH         float64
+		vertPads, horzPads int
+	)
+	for _, p := range n.pads {
+		side, _ := p.Positioning()
+		perSide[side]++
+		if pw, ph := p.Size(); pw > padW || ph > padH {
+			padW, padH = pw, ph
+		}
+	}
+	if padW == 0 {
+		padW, padH = fallbackPadSize, fallbackPadSize
+	}
+
+	if vertPads = perSide[SideLeft]; perSide[SideRight] > vertPads {
+		vertPads = perSide[SideRight]
+	}
+	if horzPads = perSide[SideTop]; perSide[SideBottom] > horzPads {
+		horzPads = perSide[SideBottom]
+	}
+
+	// Pads on the left and right are stacked below the headline, and pads
+	// on the top and bottom are spread across the width of the node.
+	if need := headlineHeight + float64(vertPads)*(autoPadSpacing+padH/2); need > h {
+		h = need
+	}
+	if need := float64(horzPads+1) * (autoPadSpacing + padW/2); need > w {
+		w = need
+	}
+	return w, h
+}
+
+func (n *AutoSizeNode) resize() {
+	w, h := n.fitSize()
+	if w < n.minW {
+		w = n.minW
+	}
+	if h < n.minH {
+		h = n.minH
+	}
+	if n.maxW > 0 && w > n.maxW {
+		w = n.maxW
+	}
+	if n.maxH > 0 && h > n.maxH {
+		h = n.maxH
+	}
+
+	if w == n.w && h == n.h {
+		return
+	}
+	n.w, n.h = w, h
+	if n.layout != nil {
+		n.layout.RecomputePadPositions(n)
+	}
+}
+
+// NewAutoSizeNode constructs a node which sizes itself to fit its headline
+// and pads, measuring the headline with m. The node is attached to the given
+// layout, which may be nil. A nil measurer estimates the width of the
+// headline with EstimateText; gofont.Measure measures it in the font used
+// by render.ImagePainter, which Cairo only approximates.
+func NewAutoSizeNode(hl, t string, l *Layout, m TextMeasurer) *AutoSizeNode {
+	if m == nil {
+		m = EstimateText
+	}
+	n := &AutoSizeNode{
+		headline: hl,
+		id:       AllocNodeID(t),
+		layout:   l,
+		measure:  m,
+		minW:     defaultMinW,
+		minH:     defaultMinH,
+		maxW:     defaultMaxW,
+		maxH:     defaultMaxH,
+	}
+	n.resize()
+	return n
+}
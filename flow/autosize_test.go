@@ -0,0 +1,50 @@
+package flow
+
+import (
+	"strings"
+	"testing"
+)
+
+// fixedMeasurer measures text as 10 units per character.
+func fixedMeasurer(text string, fontSize float64) (float64, float64) {
+	return float64(10 * len(text)), fontSize
+}
+
+func TestAutoSizeNode(t *testing.T) {
+	l := NewLayout()
+	n := NewAutoSizeNode("", "", l, fixedMeasurer)
+	if w, h := n.Size(); w != defaultMinW || h != defaultMinH {
+		t.Errorf("Size() = (%v,%v), want minimum size (%v,%v)", w, h, defaultMinW, defaultMinH)
+	}
+	if got := len(l.Dump()); got != 0 {
+		t.Errorf("node was added to the layout before being positioned")
+	}
+
+	n.SetHeadline("a much longer headline for this node")
+	if w, _ := n.Size(); w != 10*36+2*headlineInset {
+		t.Errorf("Size() width = %v, want %v", w, 10*36+2*headlineInset)
+	}
+
+	n.SetHeadline(strings.Repeat("too long ", 20))
+	if w, _ := n.Size(); w != defaultMaxW {
+		t.Errorf("Size() width = %v, want maximum %v", w, defaultMaxW)
+	}
+
+	// Adding pads should make the node taller, and move existing pads.
+	n.SetHeadline("short")
+	n.AppendSPad("", SideRight, -0.5)
+	l.MoveNode(n, 0, 0)
+	_, h1 := n.Size()
+	x1, y1 := l.Pad(n.Pads()[0]).Pos()
+	for i := 0; i < 3; i++ {
+		n.AppendSPad("", SideRight, 0.5)
+	}
+	_, h2 := n.Size()
+	x2, y2 := l.Pad(n.Pads()[0]).Pos()
+	if h2 <= h1 {
+		t.Errorf("height did not grow with pads: %v -> %v", h1, h2)
+	}
+	if x2 != x1 || y2 != -h2/4 || y2 == y1 {
+		t.Errorf("pad position = (%v,%v), want (%v,%v)", x2, y2, x1, -h2/4)
+	}
+}
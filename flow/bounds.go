@@ -0,0 +1,156 @@
+package flow
+
+// bounds describes an axis-aligned bounding box. The zero value is an
+// empty box, which does not contain any point.
+type bounds struct {
+	minX, minY float64
+	maxX, maxY float64
+	set        bool
+}
+
+// point grows the bounds to contain the given point.
+func (b *bounds) point(x, y float64) {
+	if !b.set {
+		b.minX, b.minY, b.maxX, b.maxY = x, y, x, y
+		b.set = true
+		return
+	}
+	if x < b.minX {
+		b.minX = x
+	}
+	if y < b.minY {
+		b.minY = y
+	}
+	if x > b.maxX {
+		b.maxX = x
+	}
+	if y > b.maxY {
+		b.maxY = y
+	}
+}
+
+// update grows the bounds to contain an object of the given size,
+// centered at the given position.
+func (b *bounds) update(pX, pY, sX, sY float64) {
+	b.point(pX-sX/2, pY-sY/2)
+	b.point(pX+sX/2, pY+sY/2)
+}
+
+// union grows the bounds to contain o.
+func (b *bounds) union(o bounds) {
+	if o.set {
+		b.point(o.minX, o.minY)
+		b.point(o.maxX, o.maxY)
+	}
+}
+
+// touches returns true if b lies on the outer edge of o, in which case
+// o would shrink if b was removed from it.
+func (b bounds) touches(o bounds) bool {
+	return b.set && (b.minX <= o.minX || b.minY <= o.minY || b.maxX >= o.maxX || b.maxY >= o.maxY)
+}
+
+func (b bounds) minMax() (min, max [2]float64) {
+	return [2]float64{b.minX, b.minY}, [2]float64{b.maxX, b.maxY}
+}
+
+// Bounds returns the minimum and maximum points of the area covered by all
+// nodes, pads and edge routes in the layout. Bounds are maintained as nodes
+// are moved, so calling Bounds is cheap.
+func (fl *Layout) Bounds() (min, max [2]float64) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return fl.bounds().minMax()
+}
+
+// BoundsOf returns the minimum and maximum points of the area covered by
+// the given nodes, including their pads and any edge routes starting at them.
+// Nodes which are not part of the layout are ignored.
+func (fl *Layout) BoundsOf(nodes ...Node) (min, max [2]float64) {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.NodeID()
+	}
+	fl.mu.RLock()
+	defer fl.mu.RUnlock()
+
+	var b bounds
+	for _, id := range ids {
+		b.union(fl.extents[id])
+	}
+	return b.minMax()
+}
+
+// bounds returns the bounds of the layout, caching them if they needed to
+// be recomputed. The layout must be locked for writing.
+func (fl *Layout) bounds() bounds {
+	if fl.boundsDirty {
+		fl.b = fl.unionExtents()
+		fl.boundsDirty = false
+	}
+	return fl.b
+}
+
+// currentBounds returns the bounds of the layout without caching them, so
+// the layout need only be locked for reading.
+func (fl *Layout) currentBounds() bounds {
+	if fl.boundsDirty {
+		return fl.unionExtents()
+	}
+	return fl.b
+}
+
+func (fl *Layout) unionExtents() bounds {
+	var b bounds
+	for _, e := range fl.extents {
+		b.union(e)
+	}
+	return b
+}
+
+// computeExtent returns the area covered by a node, its pads, and the
+// routes of any edges which start at it.
+func (fl *Layout) computeExtent(ni *nodeInfo) bounds {
+	var (
+		b  bounds
+		nl = fl.node(ni)
+	)
+	b.update(nl.X, nl.Y, ni.w, ni.h)
+
+	for _, pi := range ni.pads {
+		pl := fl.pad(pi)
+		b.update(pl.X, pl.Y, pi.w, pi.h)
+		for _, ei := range pi.start {
+			for _, wp := range ei.waypoints {
+				b.point(wp[0], wp[1])
+			}
+		}
+	}
+	return b
+}
+
+// updateExtent recomputes the area covered by a node, updating the bounds
+// of the layout.
+func (fl *Layout) updateExtent(ni *nodeInfo) {
+	nID := ni.id
+	if old, ok := fl.extents[nID]; ok && old.touches(fl.b) {
+		// The node may have been responsible for the current bounds, so
+		// they might need to shrink.
+		fl.boundsDirty = true
+	}
+
+	e := fl.computeExtent(ni)
+	fl.extents[nID] = e
+	fl.gen++
+	if !fl.boundsDirty {
+		fl.b.union(e)
+	}
+}
+
+func (fl *Layout) removeExtent(nID string) {
+	if old, ok := fl.extents[nID]; ok && old.touches(fl.b) {
+		fl.boundsDirty = true
+	}
+	delete(fl.extents, nID)
+	fl.gen++
+}
@@ -0,0 +1,156 @@
+package flow
+
+import "sort"
+
+// Component describes a set of nodes which are connected to each other
+// by edges, but are not connected to any other node in the layout.
+type Component struct {
+	// Root is the node the component is traversed from when drawing. Unless
+	// set with SetRoot, this is the node which was added to the layout first.
+	Root Node
+	// Nodes contains all nodes in the component, in the order they were
+	// added to the layout.
+	Nodes []Node
+}
+
+// component is a Component of captured nodes.
+type component struct {
+	root  *nodeInfo
+	nodes []*nodeInfo
+}
+
+func (c component) public() Component {
+	out := Component{Root: c.root.n, Nodes: make([]Node, len(c.nodes))}
+	for i, ni := range c.nodes {
+		out.Nodes[i] = ni.n
+	}
+	return out
+}
+
+// Components returns the connected components of the layout. Components are
+// ordered by when their first node was added to the layout, so the order
+// is stable across calls.
+func (fl *Layout) Components() []Component {
+	g := fl.captureAll()
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	fl.addDiscovered(g)
+	return publicComponents(fl.components(g))
+}
+
+// ComponentOf returns the connected component which contains the given node.
+func (fl *Layout) ComponentOf(n Node) Component {
+	g := fl.captureConnected(n)
+	ni := g.nodes[n.NodeID()]
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	fl.node(ni)
+	fl.addDiscovered(g)
+	return fl.componentFrom(g, ni, map[string]struct{}{}).public()
+}
+
+// SetRoot makes the given node the root of its connected component. If the
+// component is later merged with another component which has its own
+// explicit root, the root of the node added to the layout first is used.
+func (fl *Layout) SetRoot(n Node) {
+	g := fl.captureConnected(n)
+	ni := g.nodes[n.NodeID()]
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	fl.node(ni)
+	fl.addDiscovered(g)
+
+	for _, cn := range fl.componentFrom(g, ni, map[string]struct{}{}).nodes {
+		delete(fl.roots, cn.id)
+	}
+	fl.roots[ni.id] = struct{}{}
+}
+
+// captureConnected captures n and every node reachable from it.
+func (fl *Layout) captureConnected(n Node) *graph {
+	g := newGraph()
+	fl.mu.RLock()
+	for nID := range fl.allNodes {
+		g.known[nID] = struct{}{}
+	}
+	fl.mu.RUnlock()
+
+	g.addConnected(n)
+	return g
+}
+
+func publicComponents(cs []component) []Component {
+	out := make([]Component, len(cs))
+	for i, c := range cs {
+		out[i] = c.public()
+	}
+	return out
+}
+
+func (fl *Layout) sortNodes(nodes []*nodeInfo) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return fl.seq[nodes[i].id] < fl.seq[nodes[j].id]
+	})
+}
+
+// components computes the connected components of the captured nodes which
+// are part of the layout.
+func (fl *Layout) components(g *graph) []component {
+	var (
+		out     []component
+		nodes   = make([]*nodeInfo, 0, len(g.nodes))
+		visited = make(map[string]struct{}, len(g.nodes))
+	)
+	for nID, ni := range g.nodes {
+		if _, inLayout := fl.nodes[nID]; inLayout {
+			nodes = append(nodes, ni)
+		}
+	}
+	fl.sortNodes(nodes)
+
+	for _, ni := range nodes {
+		if _, seen := visited[ni.id]; seen {
+			continue
+		}
+		out = append(out, fl.componentFrom(g, ni, visited))
+	}
+	return out
+}
+
+// componentFrom computes the component containing the given node, marking
+// all nodes in the component as visited. Only nodes which are part of the
+// layout are included.
+func (fl *Layout) componentFrom(g *graph, ni *nodeInfo, visited map[string]struct{}) component {
+	var c component
+	visited[ni.id] = struct{}{}
+
+	for queue := []*nodeInfo{ni}; len(queue) > 0; queue = queue[1:] {
+		current := queue[0]
+		c.nodes = append(c.nodes, current)
+
+		for _, id := range current.linkIDs {
+			if _, seen := visited[id]; seen {
+				continue
+			}
+			other, captured := g.nodes[id]
+			if _, inLayout := fl.nodes[id]; !captured || !inLayout {
+				continue
+			}
+			visited[id] = struct{}{}
+			queue = append(queue, other)
+		}
+	}
+
+	fl.sortNodes(c.nodes)
+	c.root = c.nodes[0]
+	for _, cn := range c.nodes {
+		if _, isRoot := fl.roots[cn.id]; isRoot {
+			c.root = cn
+			break
+		}
+	}
+	return c
+}
@@ -47,6 +47,77 @@ type Edge interface {
 	Disconnect()
 }
 
+// RoutedEdge describes an edge which is routed through a number of
+// intermediate points, rather than directly between its pads. Whenever the
+// waypoints change, Layout.RecomputeRoute should be called so the bounds of
+// the layout cover the new route.
+type RoutedEdge interface {
+	Edge
+	Waypoints() [][2]float64
+}
+
+// CurvedEdge describes a routed edge which is drawn as a chain of cubic
+// Bezier curves when Curved returns true. The waypoints are read as two
+// control points, followed by any number of groups of a point the curve
+// passes through and two more control points, so the route ends at the
+// pad the edge leads to.
+type CurvedEdge interface {
+	RoutedEdge
+	Curved() bool
+}
+
+// CurveWaypoints returns the waypoints of e, if it is a CurvedEdge which
+// should be drawn with curves. Edges with a number of waypoints which does
+// not describe whole curves are drawn with straight lines instead, and
+// false is returned.
+func CurveWaypoints(e Edge) ([][2]float64, bool) {
+	ce, ok := e.(CurvedEdge)
+	if !ok || !ce.Curved() {
+		return nil, false
+	}
+	wps := ce.Waypoints()
+	if len(wps)%3 != 2 {
+		return nil, false
+	}
+	return wps, true
+}
+
+// Neighbours returns the distinct nodes connected to n by an edge.
+func Neighbours(n Node) []Node {
+	var (
+		out  []Node
+		seen = map[string]struct{}{n.NodeID(): {}}
+	)
+	for _, p := range n.Pads() {
+		for _, e := range append(p.StartEdges(), p.EndEdges()...) {
+			for _, ep := range []Pad{e.From(), e.To()} {
+				if ep == nil {
+					continue
+				}
+				other := ep.Parent()
+				if _, dupe := seen[other.NodeID()]; dupe {
+					continue
+				}
+				seen[other.NodeID()] = struct{}{}
+				out = append(out, other)
+			}
+		}
+	}
+	return out
+}
+
+// EdgesBetween returns the edges connecting pads a and b, in either
+// direction.
+func EdgesBetween(a, b Pad) []Edge {
+	var out []Edge
+	for _, e := range append(a.StartEdges(), a.EndEdges()...) {
+		if from, to := e.From(), e.To(); (from == a && to == b) || (from == b && to == a) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
 var ErrSelfLink = errors.New("cannot link to self")
 
 var ErrAlreadyLinked = errors.New("pads already linked")
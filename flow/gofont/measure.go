@@ -0,0 +1,72 @@
+// Package gofont measures text as drawn in the Go regular font, which is
+// the font render.ImagePainter draws text in. Cairo draws text in its own
+// default font, so on screen the measurements are only approximate.
+package gofont
+
+import (
+	"sync"
+
+	"github.com/twitchyliquid64/diagg/flow"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+)
+
+var (
+	fontOnce sync.Once
+	goFont   *sfnt.Font
+	fontErr  error
+
+	facesLock sync.Mutex
+	faces     = map[float64]font.Face{}
+)
+
+// Load parses the Go regular font, returning any error. Calling Load is
+// optional, as the font is loaded the first time text is measured.
+func Load() error {
+	_, err := Font()
+	return err
+}
+
+// Font returns the parsed Go regular font, so that text can be drawn in
+// the same font it is measured in.
+func Font() (*sfnt.Font, error) {
+	fontOnce.Do(func() {
+		goFont, fontErr = sfnt.Parse(goregular.TTF)
+	})
+	return goFont, fontErr
+}
+
+func face(fontSize float64) (font.Face, error) {
+	if err := Load(); err != nil {
+		return nil, err
+	}
+	facesLock.Lock()
+	defer facesLock.Unlock()
+
+	if f, ok := faces[fontSize]; ok {
+		return f, nil
+	}
+	f, err := opentype.NewFace(goFont, &opentype.FaceOptions{Size: fontSize, DPI: 72})
+	if err != nil {
+		return nil, err
+	}
+	faces[fontSize] = f
+	return f, nil
+}
+
+// Measure is a flow.TextMeasurer which measures text as drawn in the Go
+// regular font. If the font cannot be loaded at the given size, the size
+// of the text is estimated with flow.EstimateText instead.
+func Measure(text string, fontSize float64) (float64, float64) {
+	f, err := face(fontSize)
+	if err != nil {
+		return flow.EstimateText(text, fontSize)
+	}
+
+	facesLock.Lock()
+	defer facesLock.Unlock()
+	m := f.Metrics()
+	return float64(font.MeasureString(f, text)) / 64, float64(m.Ascent+m.Descent) / 64
+}
@@ -0,0 +1,17 @@
+package gofont
+
+import "testing"
+
+func TestMeasure(t *testing.T) {
+	if err := Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	shortW, h := Measure("ab", 16)
+	longW, _ := Measure("abcdef", 16)
+	if shortW <= 0 || longW <= shortW {
+		t.Errorf("Measure() widths = %v, %v, want increasing with length", shortW, longW)
+	}
+	if h < 16 || h > 24 {
+		t.Errorf("Measure() height = %v, want around the font size", h)
+	}
+}
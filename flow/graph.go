@@ -0,0 +1,192 @@
+package flow
+
+// nodeInfo is a copy of the structure of a node and its pads, as reported
+// by the Node, Pad and Edge implementations when it was captured.
+//
+// The layout captures the nodes it needs before taking its lock, so those
+// implementations are never called while the lock is held, and are free to
+// use the layout themselves.
+type nodeInfo struct {
+	n    Node
+	id   string
+	w, h float64
+	pads []*padInfo
+
+	// links and linkIDs are the distinct nodes connected to the node by
+	// an edge.
+	links   []Node
+	linkIDs []string
+}
+
+func (ni *nodeInfo) hasAutoPads() bool {
+	for _, pi := range ni.pads {
+		if pi.auto != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// padByID returns the captured pad of the node with the given ID, or nil if
+// the node has no such pad.
+func (ni *nodeInfo) padByID(id string) *padInfo {
+	for _, pi := range ni.pads {
+		if pi.id == id {
+			return pi
+		}
+	}
+	return nil
+}
+
+type padInfo struct {
+	p       Pad
+	id      string
+	parent  *nodeInfo
+	w, h    float64
+	side    NodeSide
+	sideAmt float64
+
+	// auto is set if the layout chooses the positioning of the pad, and
+	// placed is set once it has changed it.
+	auto   AutoPositionedPad
+	placed bool
+
+	start, end []*edgeInfo
+}
+
+type edgeInfo struct {
+	e                    Edge
+	id                   string
+	from, to             Pad
+	fromID, toID         string
+	fromNode, toNode     Node
+	fromNodeID, toNodeID string
+	waypoints            [][2]float64
+}
+
+// graph is a copy of the structure of part of a flowchart.
+type graph struct {
+	nodes map[string]*nodeInfo
+	pads  map[string]*padInfo
+	// known contains the IDs of the nodes in the layout before capture
+	// began. Nodes which are known but no longer in the layout were deleted
+	// while the graph was captured, and are not added back.
+	known map[string]struct{}
+}
+
+func newGraph() *graph {
+	return &graph{
+		nodes: map[string]*nodeInfo{},
+		pads:  map[string]*padInfo{},
+		known: map[string]struct{}{},
+	}
+}
+
+// add captures n, if it has not been captured already.
+func (g *graph) add(n Node) *nodeInfo {
+	id := n.NodeID()
+	if ni, ok := g.nodes[id]; ok {
+		return ni
+	}
+	ni := &nodeInfo{n: n, id: id}
+	ni.w, ni.h = n.Size()
+	g.nodes[id] = ni
+
+	seen := map[string]struct{}{id: {}}
+	link := func(other Node, otherID string) {
+		if _, dupe := seen[otherID]; !dupe {
+			seen[otherID] = struct{}{}
+			ni.links = append(ni.links, other)
+			ni.linkIDs = append(ni.linkIDs, otherID)
+		}
+	}
+	for _, p := range n.Pads() {
+		pi := capturePad(p, ni)
+		ni.pads = append(ni.pads, pi)
+		g.pads[pi.id] = pi
+
+		for _, ei := range append(pi.start, pi.end...) {
+			link(ei.fromNode, ei.fromNodeID)
+			link(ei.toNode, ei.toNodeID)
+		}
+	}
+	return ni
+}
+
+// addNeighbours captures n and the nodes connected to it.
+func (g *graph) addNeighbours(n Node) *nodeInfo {
+	ni := g.add(n)
+	for _, other := range ni.links {
+		g.add(other)
+	}
+	return ni
+}
+
+// addConnected captures n and every node reachable from it.
+func (g *graph) addConnected(n Node) *nodeInfo {
+	ni := g.add(n)
+	for queue := []*nodeInfo{ni}; len(queue) > 0; queue = queue[1:] {
+		for _, other := range queue[0].links {
+			if _, seen := g.nodes[other.NodeID()]; !seen {
+				queue = append(queue, g.add(other))
+			}
+		}
+	}
+	return ni
+}
+
+// applyPositioning reports the positioning chosen by the layout to any
+// automatically positioned pads. It must be called without the lock of the
+// layout held, so the pads are responsible for guarding their positioning
+// against concurrent reads, as SPad does.
+func (g *graph) applyPositioning() {
+	for _, pi := range g.pads {
+		if pi.placed {
+			pi.auto.SetPositioning(pi.side, pi.sideAmt)
+		}
+	}
+}
+
+func capturePad(p Pad, parent *nodeInfo) *padInfo {
+	pi := &padInfo{p: p, id: p.PadID(), parent: parent}
+	pi.w, pi.h = p.Size()
+	pi.side, pi.sideAmt = p.Positioning()
+	if ap, ok := p.(AutoPositionedPad); ok && ap.AutoPositioned() {
+		pi.auto = ap
+	}
+	for _, e := range p.StartEdges() {
+		if ei := captureEdge(e); ei != nil {
+			pi.start = append(pi.start, ei)
+		}
+	}
+	for _, e := range p.EndEdges() {
+		if ei := captureEdge(e); ei != nil {
+			pi.end = append(pi.end, ei)
+		}
+	}
+	return pi
+}
+
+// captureEdge captures an edge, returning nil if either end of the edge
+// is not connected.
+func captureEdge(e Edge) *edgeInfo {
+	from, to := e.From(), e.To()
+	if from == nil || to == nil {
+		return nil
+	}
+	ei := &edgeInfo{
+		e:        e,
+		id:       e.EdgeID(),
+		from:     from,
+		to:       to,
+		fromID:   from.PadID(),
+		toID:     to.PadID(),
+		fromNode: from.Parent(),
+		toNode:   to.Parent(),
+	}
+	ei.fromNodeID, ei.toNodeID = ei.fromNode.NodeID(), ei.toNode.NodeID()
+	if re, ok := e.(RoutedEdge); ok {
+		ei.waypoints = re.Waypoints()
+	}
+	return ei
+}
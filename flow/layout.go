@@ -1,33 +1,48 @@
 package flow
 
-import (
-	"errors"
-)
-
-// ErrIsRoot is returned if deletion of the root node is attempted.
-var ErrIsRoot = errors.New("cannot delete the root node")
+import "sync"
 
 // NodeLayout describes the layout state of a flowchart node.
 type NodeLayout struct {
 	X, Y float64
+
+	// mu is the lock of the owning layout, and is nil if the state is not
+	// owned by a live layout (such as in a snapshot).
+	mu *sync.RWMutex
 }
 
+// Pos returns the position of the node. It is safe to call concurrently
+// with mutations of the owning layout.
 func (fns *NodeLayout) Pos() (float64, float64) {
 	if fns == nil {
 		return 0, 0
 	}
+	if fns.mu != nil {
+		fns.mu.RLock()
+		defer fns.mu.RUnlock()
+	}
 	return fns.X, fns.Y
 }
 
 // PadLayout describes the layout state of a flowchart pad.
 type PadLayout struct {
 	X, Y float64
+
+	// mu is the lock of the owning layout, and is nil if the state is not
+	// owned by a live layout (such as in a snapshot).
+	mu *sync.RWMutex
 }
 
+// Pos returns the position of the pad. It is safe to call concurrently
+// with mutations of the owning layout.
 func (fps *PadLayout) Pos() (float64, float64) {
 	if fps == nil {
 		return 0, 0
 	}
+	if fps.mu != nil {
+		fps.mu.RLock()
+		defer fps.mu.RUnlock()
+	}
 	return fps.X, fps.Y
 }
 
@@ -38,57 +53,50 @@ func NewLayout() *Layout {
 		allNodes: map[string]Node{},
 		nodes:    map[string]*NodeLayout{},
 		pads:     map[string]*PadLayout{},
+		seq:      map[string]uint64{},
+		roots:    map[string]struct{}{},
+		extents:  map[string]bounds{},
 	}
 }
 
 // Layout keeps track of state describing how elements of a flowchart
-// should be positioned.
+// should be positioned. A Layout is safe for concurrent use.
+//
+// The layout never calls into nodes, pads or edges while it is locked, so
+// their methods may use the layout themselves.
 type Layout struct {
-	root     Node
+	// mu guards all fields, as well as the fields of any NodeLayout or
+	// PadLayout owned by the layout.
+	mu sync.RWMutex
+
 	allNodes map[string]Node
 	nodes    map[string]*NodeLayout
 	pads     map[string]*PadLayout
-}
-
-type positionable interface {
-	Pos() (float64, float64)
-}
 
-type sizeable interface {
-	Size() (float64, float64)
-}
-
-type bounds struct {
-	minX, minY float64
-	maxX, maxY float64
-}
-
-func (b *bounds) update(pos positionable, size sizeable) {
-	pX, pY := pos.Pos()
-	sX, sY := size.Size()
-
-	lowerX, lowerY := pX-sX/2, pY-sY/2
-	if lowerX < b.minX {
-		b.minX = lowerX
-	}
-	if lowerY < b.minY {
-		b.minY = lowerY
-	}
-
-	higherX, higherY := pX+sX/2, pY+sY/2
-	if higherX > b.maxX {
-		b.maxX = higherX
-	}
-	if higherY > b.maxY {
-		b.maxY = higherY
-	}
+	// seq records the order in which nodes were added to the layout, which
+	// is used to keep the draw order stable.
+	seq     map[string]uint64
+	nextSeq uint64
+	// roots contains the IDs of nodes which were explicitly made the root
+	// of their component.
+	roots map[string]struct{}
+
+	// extents maps node IDs to the area covered by the node, its pads,
+	// and any edge routes starting at it.
+	extents map[string]bounds
+	// b is the union of all extents, which is recomputed from extents
+	// only when boundsDirty is set.
+	b           bounds
+	boundsDirty bool
+
+	// gen is incremented whenever the area covered by any node changes.
+	gen uint64
 }
 
 type dlState struct {
 	renderedNodes map[string]struct{}
 	renderedPads  map[string]struct{}
 	renderedEdges map[string]struct{}
-	bounds        *bounds
 }
 
 type DrawObject uint8
@@ -132,82 +140,146 @@ type DrawCommand interface {
 	DrawObject() DrawObject
 }
 
+// MoveNode sets the position of a node, adding it to the layout if
+// necessary.
 func (fl *Layout) MoveNode(n Node, x, y float64) {
-	nID := n.NodeID()
-	if nl, ok := fl.nodes[nID]; ok {
+	g := newGraph()
+	ni := g.addNeighbours(n)
+
+	fl.mu.Lock()
+	if nl, ok := fl.nodes[ni.id]; ok {
 		nl.X = x
 		nl.Y = y
 	} else {
-		fl.nodes[nID] = &NodeLayout{X: x, Y: y}
-		fl.allNodes[nID] = n
+		fl.nodes[ni.id] = &NodeLayout{X: x, Y: y, mu: &fl.mu}
+		fl.allNodes[ni.id] = n
+		fl.seq[ni.id] = fl.nextSeq
+		fl.nextSeq++
 	}
 
 	// As pad position is dependent on node position, force recomputation.
-	for _, p := range n.Pads() {
-		fl.padPosRecompute(p)
-	}
+	fl.relayoutNode(ni)
+	fl.relayoutNeighbours(g, ni)
+	fl.mu.Unlock()
+	g.applyPositioning()
 }
 
 // RecomputePadPositions should be called by the user after the position of
-// any pad on the node changes.
+// any pad on the node, or the size of the node, changes. Nodes which are not
+// part of the layout are ignored.
 func (fl *Layout) RecomputePadPositions(n Node) {
-	for _, p := range n.Pads() {
-		fl.padPosRecompute(p)
+	g := newGraph()
+	ni := g.addNeighbours(n)
+
+	fl.mu.Lock()
+	if _, ok := fl.nodes[ni.id]; ok {
+		fl.relayoutNode(ni)
+		fl.relayoutNeighbours(g, ni)
 	}
+	fl.mu.Unlock()
+	g.applyPositioning()
 }
 
-// DeleteNode removes a node from the layout, destroying all edges to other
-// nodes in the layout.
-func (fl *Layout) DeleteNode(n Node) {
-	nID := n.NodeID()
-	if fl.root != nil && nID == fl.root.NodeID() {
-		fl.findNewRoot()
+// relayoutNode recomputes the position of all pads on a node, choosing the
+// side of any automatically positioned pads first.
+func (fl *Layout) relayoutNode(ni *nodeInfo) {
+	fl.assignPadSides(ni)
+	for _, pi := range ni.pads {
+		fl.padPosRecompute(pi)
 	}
+	fl.updateExtent(ni)
+}
 
-	delete(fl.nodes, nID)
-	delete(fl.allNodes, nID)
+// relayoutNeighbours recomputes the position of pads on nodes connected to
+// the given node, if they have any automatically positioned pads.
+func (fl *Layout) relayoutNeighbours(g *graph, ni *nodeInfo) {
+	for _, id := range ni.linkIDs {
+		other := g.nodes[id]
+		if _, inLayout := fl.nodes[id]; inLayout && other != nil && other.hasAutoPads() {
+			fl.relayoutNode(other)
+		}
+	}
+}
 
+// DeleteNode removes a node from the layout, destroying all edges to other
+// nodes in the layout. Any node may be deleted, including the root of a
+// component, in which case the component is next drawn from the node which
+// was added to the layout first.
+func (fl *Layout) DeleteNode(n Node) {
+	// The edges are disconnected before the layout is locked, as that calls
+	// into the pads of the node and its neighbours.
+	var (
+		affected = Neighbours(n)
+		nID      = n.NodeID()
+		padIDs   []string
+	)
 	for _, p := range n.Pads() {
 		p.DisconnectAll()
-		delete(fl.pads, p.PadID())
+		padIDs = append(padIDs, p.PadID())
 	}
-}
-
-func (fl *Layout) findNewRoot() {
-	// Try and select a root adjacent to the existing root if there is one.
-	if fl.root != nil {
-		for _, p := range fl.root.Pads() {
-			for _, e := range p.StartEdges() {
-				if fl.root.NodeID() != e.To().Parent().NodeID() {
-					fl.root = e.To().Parent()
-					return
-				}
-			}
-		}
+	g := newGraph()
+	for _, other := range affected {
+		g.add(other)
 	}
-	// Otherwise, anything will do.
-	for nID, n := range fl.allNodes {
-		if fl.root != nil && nID == fl.root.NodeID() {
-			continue
+
+	fl.mu.Lock()
+	delete(fl.nodes, nID)
+	delete(fl.allNodes, nID)
+	delete(fl.seq, nID)
+	delete(fl.roots, nID)
+	fl.removeExtent(nID)
+	for _, pID := range padIDs {
+		delete(fl.pads, pID)
+	}
+	for _, other := range g.nodes {
+		if _, inLayout := fl.nodes[other.id]; inLayout && other.hasAutoPads() {
+			fl.relayoutNode(other)
 		}
-		fl.root = n
-		return
 	}
-	fl.root = nil
+	fl.mu.Unlock()
+	g.applyPositioning()
 }
 
 // Node returns the object storing the position of a node.
 func (fl *Layout) Node(n Node) *NodeLayout {
 	nID := n.NodeID()
-	if nl, ok := fl.nodes[nID]; ok {
+	fl.mu.RLock()
+	nl, ok := fl.nodes[nID]
+	fl.mu.RUnlock()
+	if ok {
 		return nl
 	}
-	nl := &NodeLayout{}
-	fl.nodes[nID] = nl
-	fl.allNodes[nID] = n
+
+	ni := newGraph().add(n)
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return fl.node(ni)
+}
+
+// node returns the position of a node, adding it to the layout if
+// necessary.
+func (fl *Layout) node(ni *nodeInfo) *NodeLayout {
+	if nl, ok := fl.nodes[ni.id]; ok {
+		return nl
+	}
+	nl := &NodeLayout{mu: &fl.mu}
+	fl.nodes[ni.id] = nl
+	fl.allNodes[ni.id] = ni.n
+	fl.seq[ni.id] = fl.nextSeq
+	fl.nextSeq++
+	fl.updateExtent(ni)
 	return nl
 }
 
+// Generation returns a counter which is incremented whenever the position
+// or extent of a node in the layout changes, so callers can cheaply detect
+// when anything derived from the layout needs to be recomputed.
+func (fl *Layout) Generation() uint64 {
+	fl.mu.RLock()
+	defer fl.mu.RUnlock()
+	return fl.gen
+}
+
 type NodePosition struct {
 	Pos  NodeLayout
 	Node Node
@@ -215,29 +287,32 @@ type NodePosition struct {
 
 // Dump returns a list of all nodes and their positions.
 func (fl *Layout) Dump() []NodePosition {
+	fl.mu.RLock()
+	defer fl.mu.RUnlock()
+
 	out := make([]NodePosition, 0, len(fl.allNodes))
-	for _, n := range fl.allNodes {
+	for nID, n := range fl.allNodes {
+		nl := fl.nodes[nID]
 		out = append(out, NodePosition{
 			Node: n,
-			Pos:  *fl.Node(n),
+			Pos:  NodeLayout{X: nl.X, Y: nl.Y},
 		})
 	}
 	return out
 }
 
-func (fl *Layout) padPosRecompute(p Pad) *PadLayout {
+func (fl *Layout) padPosRecompute(pi *padInfo) *PadLayout {
 	var (
-		side, sideAmt = p.Positioning()
-		parentLayout  = fl.Node(p.Parent())
-		w, h          = p.Parent().Size()
-		pID           = p.PadID()
-		pl            = fl.pads[pID]
+		parentLayout = fl.node(pi.parent)
+		w, h         = pi.parent.w, pi.parent.h
+		sideAmt      = pi.sideAmt
+		pl           = fl.pads[pi.id]
 	)
 	if pl == nil {
-		pl = &PadLayout{}
+		pl = &PadLayout{mu: &fl.mu}
 	}
 
-	switch side {
+	switch pi.side {
 	case SideRight:
 		pl.X, pl.Y = parentLayout.X+w/2, parentLayout.Y+h*sideAmt/2
 	case SideLeft:
@@ -248,123 +323,230 @@ func (fl *Layout) padPosRecompute(p Pad) *PadLayout {
 		pl.X, pl.Y = parentLayout.X+w*sideAmt/2, parentLayout.Y-h/2
 	}
 
-	fl.pads[pID] = pl
+	fl.pads[pi.id] = pl
 	return pl
 }
 
+// RecomputeRoute should be called by the user after the waypoints of a
+// RoutedEdge change, so they are reflected in the bounds of the layout.
+func (fl *Layout) RecomputeRoute(e Edge) {
+	from := e.From()
+	if from == nil {
+		return
+	}
+	ni := newGraph().add(from.Parent())
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	if _, ok := fl.nodes[ni.id]; ok {
+		fl.updateExtent(ni)
+	}
+}
+
+// Pad returns the object storing the position of a pad.
 func (fl *Layout) Pad(p Pad) *PadLayout {
 	pID := p.PadID()
-	if pl, ok := fl.pads[pID]; ok {
+	fl.mu.RLock()
+	pl, ok := fl.pads[pID]
+	fl.mu.RUnlock()
+	if ok {
+		return pl
+	}
+
+	g := newGraph()
+	pi := g.add(p.Parent()).padByID(pID)
+	if pi == nil {
+		// The pad is not listed by its parent.
+		pi = capturePad(p, g.nodes[p.Parent().NodeID()])
+	}
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return fl.pad(pi)
+}
+
+func (fl *Layout) pad(pi *padInfo) *PadLayout {
+	if pl, ok := fl.pads[pi.id]; ok {
 		return pl
 	}
-	return fl.padPosRecompute(p)
+	return fl.padPosRecompute(pi)
+}
+
+// captureAll captures every node in the layout, along with any nodes
+// connected to them.
+func (fl *Layout) captureAll() *graph {
+	g := newGraph()
+	fl.mu.RLock()
+	nodes := make([]Node, 0, len(fl.allNodes))
+	for nID, n := range fl.allNodes {
+		nodes = append(nodes, n)
+		g.known[nID] = struct{}{}
+	}
+	fl.mu.RUnlock()
+
+	for _, n := range nodes {
+		g.addConnected(n)
+	}
+	return g
+}
+
+// addDiscovered adds captured nodes which are connected to nodes in the
+// layout but were never added to it, and computes the position of any
+// captured pads which are not yet known.
+func (fl *Layout) addDiscovered(g *graph) {
+	for nID, ni := range g.nodes {
+		_, inLayout := fl.nodes[nID]
+		if _, known := g.known[nID]; !inLayout && !known {
+			fl.node(ni)
+		}
+	}
+	for _, pi := range g.pads {
+		if _, inLayout := fl.nodes[pi.parent.id]; inLayout {
+			fl.pad(pi)
+		}
+	}
+}
+
+// hasUndiscovered returns true if addDiscovered would add anything to the
+// layout.
+func (fl *Layout) hasUndiscovered(g *graph) bool {
+	for nID := range g.nodes {
+		_, inLayout := fl.nodes[nID]
+		if _, known := g.known[nID]; !inLayout && !known {
+			return true
+		}
+	}
+	for pID, pi := range g.pads {
+		_, parentInLayout := fl.nodes[pi.parent.id]
+		if _, inLayout := fl.pads[pID]; parentInLayout && !inLayout {
+			return true
+		}
+	}
+	return false
 }
 
 // DisplayList returns a list of drawing commands for rendering the layout.
-// The bounds of all objects in the layout are also returned.
+// The bounds of all objects in the layout, as returned by Bounds(), are
+// also returned.
 func (fl *Layout) DisplayList() (min, max [2]float64, dl []DrawCommand, err error) {
-	if fl.root == nil {
-		fl.findNewRoot()
+	g := fl.captureAll()
+
+	// The layout is only written to if there are connected nodes or pads
+	// which it does not know the position of yet.
+	fl.mu.RLock()
+	if fl.hasUndiscovered(g) {
+		fl.mu.RUnlock()
+		fl.mu.Lock()
+		defer fl.mu.Unlock()
+		fl.addDiscovered(g)
+	} else {
+		defer fl.mu.RUnlock()
 	}
-	if fl.root == nil {
+	return fl.displayList(g)
+}
+
+// displayList computes the display list of the captured graph. All nodes
+// and pads in the graph which are part of the layout must already have a
+// position, as the layout is not modified.
+func (fl *Layout) displayList(g *graph) (min, max [2]float64, dl []DrawCommand, err error) {
+	components := fl.components(g)
+	if len(components) == 0 {
 		// No nodes to render.
 		return [2]float64{}, [2]float64{}, nil, nil
 	}
 
-	b := &bounds{}
-	b.update(fl.nodes[fl.root.NodeID()], fl.root)
-
 	state := dlState{
 		renderedNodes: make(map[string]struct{}, 4+len(fl.nodes)),
 		renderedPads:  make(map[string]struct{}, 12+len(fl.pads)),
 		renderedEdges: make(map[string]struct{}, 32),
-		bounds:        b,
-	}
-
-	dl, err = fl.populateDrawListNode(make([]DrawCommand, 0, 256), fl.root, state)
-	if err != nil {
-		return [2]float64{}, [2]float64{}, nil, err
 	}
 
-	// Run through the full list again to make sure we have any orphaned nodes.
-	for _, n := range fl.allNodes {
-		dl, err = fl.populateDrawListNode(dl, n, state)
+	// Each component is drawn in turn, starting from its root, so the draw
+	// order only changes when the structure of the flowchart does.
+	dl = make([]DrawCommand, 0, 256)
+	for _, c := range components {
+		if dl, err = fl.populateDrawListNode(dl, g, c.root, state); err != nil {
+			return [2]float64{}, [2]float64{}, nil, err
+		}
 	}
 
-	return [2]float64{b.minX, b.minY}, [2]float64{b.maxY, b.maxY}, dl, err
+	min, max = fl.currentBounds().minMax()
+	return min, max, dl, err
 }
 
-func (fl *Layout) populateDrawListNode(outList []DrawCommand, n Node, s dlState) ([]DrawCommand, error) {
-	nID := n.NodeID()
-	if _, alreadyProcessed := s.renderedNodes[nID]; alreadyProcessed {
+func (fl *Layout) populateDrawListNode(outList []DrawCommand, g *graph, ni *nodeInfo, s dlState) ([]DrawCommand, error) {
+	if _, alreadyProcessed := s.renderedNodes[ni.id]; alreadyProcessed {
+		return outList, nil
+	}
+	s.renderedNodes[ni.id] = struct{}{}
+	nl, inLayout := fl.nodes[ni.id]
+	if !inLayout {
+		// The node was deleted while the graph was captured.
 		return outList, nil
 	}
-	s.renderedNodes[nID] = struct{}{}
-	nl := fl.Node(n)
-	outList = append(outList, DrawNodeCmd{Node: n, Layout: nl})
-	s.bounds.update(nl, n)
+	outList = append(outList, DrawNodeCmd{Node: ni.n, Layout: nl})
 
-	for _, p := range n.Pads() {
+	for _, pi := range ni.pads {
 		var err error
-		if outList, err = fl.populateDrawListPad(outList, p, n, s); err != nil {
+		if outList, err = fl.populateDrawListPad(outList, g, pi, s); err != nil {
 			return nil, err
 		}
 	}
 	return outList, nil
 }
 
-func (fl *Layout) populateDrawListPad(outList []DrawCommand, p Pad, parent Node, s dlState) ([]DrawCommand, error) {
-	pID := p.PadID()
-	if _, alreadyProcessed := s.renderedPads[pID]; alreadyProcessed {
+func (fl *Layout) populateDrawListPad(outList []DrawCommand, g *graph, pi *padInfo, s dlState) ([]DrawCommand, error) {
+	if _, alreadyProcessed := s.renderedPads[pi.id]; alreadyProcessed {
 		return outList, nil
 	}
 
-	s.renderedPads[pID] = struct{}{}
-	pl := fl.Pad(p)
-	outList = append(outList, DrawPadCmd{Pad: p, Layout: pl})
-	s.bounds.update(pl, p)
+	s.renderedPads[pi.id] = struct{}{}
+	outList = append(outList, DrawPadCmd{Pad: pi.p, Layout: fl.pads[pi.id]})
 
-	for _, se := range p.StartEdges() {
+	for _, se := range pi.start {
 		var err error
-		if outList, err = fl.populateDrawListEdge(outList, se, p, s); err != nil {
+		if outList, err = fl.populateDrawListEdge(outList, g, se, s); err != nil {
 			return nil, err
 		}
 	}
-	for _, ee := range p.EndEdges() {
+	for _, ee := range pi.end {
 		var err error
-		if outList, err = fl.populateDrawListEdge(outList, ee, p, s); err != nil {
+		if outList, err = fl.populateDrawListEdge(outList, g, ee, s); err != nil {
 			return nil, err
 		}
 	}
 	return outList, nil
 }
 
-func (fl *Layout) populateDrawListEdge(outList []DrawCommand, e Edge, parent Pad, s dlState) ([]DrawCommand, error) {
-	eID := e.EdgeID()
-	if _, alreadyProcessed := s.renderedEdges[eID]; alreadyProcessed {
+func (fl *Layout) populateDrawListEdge(outList []DrawCommand, g *graph, ei *edgeInfo, s dlState) ([]DrawCommand, error) {
+	if _, alreadyProcessed := s.renderedEdges[ei.id]; alreadyProcessed {
 		return outList, nil
 	}
-	s.renderedEdges[eID] = struct{}{}
+	s.renderedEdges[ei.id] = struct{}{}
 
-	// In case the referenced pad has not been rendered, render it before the
-	// edge so the edge appears on top.
 	var (
-		err            error
-		toPad, fromPad = e.To(), e.From()
-		toPl, fromPl   = fl.Pad(toPad), fl.Pad(fromPad)
+		err              error
+		toPl, fromPl     = fl.pads[ei.toID], fl.pads[ei.fromID]
+		toNode, fromNode = g.nodes[ei.toNodeID], g.nodes[ei.fromNodeID]
 	)
-	if outList, err = fl.populateDrawListNode(outList, toPad.Parent(), s); err != nil {
+	if toPl == nil || fromPl == nil || toNode == nil || fromNode == nil {
+		// One end of the edge was deleted while the graph was captured.
+		return outList, nil
+	}
+
+	// In case the referenced pad has not been rendered, render it before the
+	// edge so the edge appears on top.
+	if outList, err = fl.populateDrawListNode(outList, g, toNode, s); err != nil {
 		return nil, err
 	}
-	if outList, err = fl.populateDrawListNode(outList, fromPad.Parent(), s); err != nil {
+	if outList, err = fl.populateDrawListNode(outList, g, fromNode, s); err != nil {
 		return nil, err
 	}
 
 	return append(outList, DrawEdgeCmd{
-		From:       fromPad,
-		To:         toPad,
+		From:       ei.from,
+		To:         ei.to,
 		FromLayout: fromPl,
 		ToLayout:   toPl,
-		Edge:       e,
+		Edge:       ei.e,
 	}), nil
 }
@@ -0,0 +1,489 @@
+package flow
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLayoutConcurrentMutation(t *testing.T) {
+	l := NewLayout()
+	nodes := make([]*SNode, 8)
+	for i := range nodes {
+		nodes[i] = NewSNode("node", "")
+		nodes[i].AppendSPad("", SideRight, 0)
+		l.MoveNode(nodes[i], float64(i*250), 0)
+	}
+
+	var wg sync.WaitGroup
+	for i := range nodes {
+		wg.Add(2)
+		go func(n *SNode) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				l.MoveNode(n, float64(j), float64(j))
+			}
+		}(nodes[i])
+		go func(n *SNode) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				l.Node(n).Pos()
+				l.Pad(n.Pads()[0]).Pos()
+			}
+		}(nodes[i])
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			if _, _, _, err := l.Snapshot().DisplayList(); err != nil {
+				t.Errorf("DisplayList() failed: %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	if got := len(l.Dump()); got != len(nodes) {
+		t.Errorf("len(Dump()) = %d, want %d", got, len(nodes))
+	}
+}
+
+func TestSnapshotIsolated(t *testing.T) {
+	l := NewLayout()
+	n := NewSNode("node", "")
+	n.AppendSPad("", SideRight, 0)
+	l.MoveNode(n, 10, 20)
+
+	s := l.Snapshot()
+	l.MoveNode(n, 500, 600)
+
+	if x, y := s.Node(n).Pos(); x != 10 || y != 20 {
+		t.Errorf("snapshot node position = (%v,%v), want (10,20)", x, y)
+	}
+	if x, y := s.Pad(n.Pads()[0]).Pos(); x != 110 || y != 20 {
+		t.Errorf("snapshot pad position = (%v,%v), want (110,20)", x, y)
+	}
+	if x, y := l.Node(n).Pos(); x != 500 || y != 600 {
+		t.Errorf("layout node position = (%v,%v), want (500,600)", x, y)
+	}
+
+	_, _, dl, err := s.DisplayList()
+	if err != nil {
+		t.Fatalf("DisplayList() failed: %v", err)
+	}
+	if len(dl) != 2 {
+		t.Fatalf("len(DisplayList()) = %d, want 2", len(dl))
+	}
+	if x, y := dl[0].(DrawNodeCmd).Layout.Pos(); x != 10 || y != 20 {
+		t.Errorf("display list node position = (%v,%v), want (10,20)", x, y)
+	}
+
+	// Nodes linked after the snapshot should not appear in it.
+	other := NewSNode("other", "")
+	other.AppendSPad("", SideLeft, 0)
+	if _, err := n.LinkPads(other, n.Pads()[0], other.Pads()[0]); err != nil {
+		t.Fatalf("LinkPads() failed: %v", err)
+	}
+	if _, _, dl, _ := s.DisplayList(); len(dl) != 2 {
+		t.Errorf("len(DisplayList()) after link = %d, want 2", len(dl))
+	}
+	if got := len(s.Components()[0].Nodes); got != 1 {
+		t.Errorf("len(Components()[0].Nodes) after link = %d, want 1", got)
+	}
+	if s.Node(other) != nil {
+		t.Errorf("Node() of node linked after snapshot = %v, want nil", s.Node(other))
+	}
+}
+
+// followerNode is sized from the position of another node in the layout.
+type followerNode struct {
+	*SNode
+	l      *Layout
+	leader Node
+}
+
+func (n *followerNode) Size() (float64, float64) {
+	x, _ := n.l.Node(n.leader).Pos()
+	return 100 + x/10, 100
+}
+
+// relayoutPad asks the layout to reposition its node whenever its own
+// positioning is changed.
+type relayoutPad struct {
+	*SPad
+	l *Layout
+}
+
+func (p *relayoutPad) SetPositioning(side NodeSide, sideAmt float64) {
+	p.SPad.SetPositioning(side, sideAmt)
+	p.l.RecomputePadPositions(p.Parent())
+}
+
+func TestLayoutReentrant(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l := NewLayout()
+		leader := NewSNode("leader", "")
+		leader.AppendSPad("", SideLeft, 0)
+		l.MoveNode(leader, 500, 0)
+
+		f := &followerNode{SNode: NewSNode("follower", ""), l: l, leader: leader}
+		p := &relayoutPad{SPad: NewSPad("", f, SideLeft, 0), l: l}
+		p.SetAutoPositioned(true)
+		f.AppendPad(p)
+		l.MoveNode(f, 0, 0)
+		if _, err := f.LinkPads(leader, p, leader.Pads()[0]); err != nil {
+			t.Errorf("LinkPads() failed: %v", err)
+		}
+
+		l.MoveNode(leader, 1000, 0)
+		if side, _ := p.Positioning(); side != SideRight {
+			t.Errorf("Positioning() side = %v, want %v", side, SideRight)
+		}
+		if _, _, _, err := l.DisplayList(); err != nil {
+			t.Errorf("DisplayList() failed: %v", err)
+		}
+		l.Snapshot()
+		l.Components()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("layout deadlocked calling into a node")
+	}
+}
+
+func linkedPair(t *testing.T, l *Layout, x float64) (*SNode, *SNode) {
+	t.Helper()
+	a, b := NewSNode("a", ""), NewSNode("b", "")
+	a.AppendSPad("", SideRight, 0)
+	b.AppendSPad("", SideLeft, 0)
+	l.MoveNode(a, x, 0)
+	l.MoveNode(b, x+300, 0)
+	if _, err := a.LinkPads(b, a.Pads()[0], b.Pads()[0]); err != nil {
+		t.Fatalf("LinkPads() failed: %v", err)
+	}
+	return a, b
+}
+
+func TestLayoutComponents(t *testing.T) {
+	l := NewLayout()
+	a1, b1 := linkedPair(t, l, 0)
+	a2, b2 := linkedPair(t, l, 1000)
+	lone := NewSNode("lone", "")
+	l.MoveNode(lone, 0, 500)
+
+	cs := l.Components()
+	if len(cs) != 3 {
+		t.Fatalf("len(Components()) = %d, want 3", len(cs))
+	}
+	for i, want := range [][]Node{{a1, b1}, {a2, b2}, {lone}} {
+		if cs[i].Root != want[0] {
+			t.Errorf("component %d root = %v, want %v", i, cs[i].Root.NodeID(), want[0].NodeID())
+		}
+		if len(cs[i].Nodes) != len(want) {
+			t.Errorf("component %d has %d nodes, want %d", i, len(cs[i].Nodes), len(want))
+			continue
+		}
+		for j := range want {
+			if cs[i].Nodes[j] != want[j] {
+				t.Errorf("component %d node %d = %v, want %v", i, j, cs[i].Nodes[j].NodeID(), want[j].NodeID())
+			}
+		}
+	}
+
+	l.SetRoot(b2)
+	if got := l.ComponentOf(a2).Root; got != b2 {
+		t.Errorf("ComponentOf(a2).Root = %v, want %v", got.NodeID(), b2.NodeID())
+	}
+
+	// The root of a component should move on when it is deleted.
+	l.DeleteNode(a1)
+	if got := l.ComponentOf(b1).Root; got != b1 {
+		t.Errorf("ComponentOf(b1).Root = %v, want %v", got.NodeID(), b1.NodeID())
+	}
+}
+
+func TestDisplayListStable(t *testing.T) {
+	l := NewLayout()
+	for i := 0; i < 5; i++ {
+		linkedPair(t, l, float64(i*1000))
+	}
+
+	_, _, first, err := l.DisplayList()
+	if err != nil {
+		t.Fatalf("DisplayList() failed: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		_, _, dl, err := l.DisplayList()
+		if err != nil {
+			t.Fatalf("DisplayList() failed: %v", err)
+		}
+		if len(dl) != len(first) {
+			t.Fatalf("len(DisplayList()) = %d, want %d", len(dl), len(first))
+		}
+		for j := range dl {
+			if dl[j] != first[j] {
+				t.Fatalf("DisplayList()[%d] = %+v, want %+v", j, dl[j], first[j])
+			}
+		}
+	}
+}
+
+type routedEdge struct {
+	*SEdge
+	waypoints [][2]float64
+}
+
+func (e *routedEdge) Waypoints() [][2]float64 { return e.waypoints }
+
+type curvedEdge struct {
+	routedEdge
+	curved bool
+}
+
+func (e *curvedEdge) Curved() bool { return e.curved }
+
+func TestEdgesBetween(t *testing.T) {
+	a, b, c := NewSNode("a", ""), NewSNode("b", ""), NewSNode("c", "")
+	a.AppendSPad("", SideRight, 0)
+	b.AppendSPad("", SideLeft, 0)
+	c.AppendSPad("", SideLeft, 0)
+	pa, pb, pc := a.Pads()[0], b.Pads()[0], c.Pads()[0]
+
+	e, err := a.LinkPads(b, pa, pb)
+	if err != nil {
+		t.Fatalf("LinkPads() failed: %v", err)
+	}
+	if got := EdgesBetween(pb, pa); len(got) != 1 || got[0] != e {
+		t.Errorf("EdgesBetween(b, a) = %v, want [%v]", got, e)
+	}
+	if got := EdgesBetween(pa, pc); len(got) != 0 {
+		t.Errorf("EdgesBetween(a, c) = %v, want none", got)
+	}
+	if _, err := b.LinkPads(a, pb, pa); err != ErrAlreadyLinked {
+		t.Errorf("LinkPads() in reverse err = %v, want %v", err, ErrAlreadyLinked)
+	}
+}
+
+func TestCurveWaypoints(t *testing.T) {
+	wps := [][2]float64{{0, 50}, {100, 50}, {100, 0}, {100, -50}, {200, -50}}
+	tcs := []struct {
+		name string
+		e    Edge
+		want bool
+	}{
+		{"plain", NewSEdge("", nil, nil), false},
+		{"routed", &routedEdge{waypoints: wps}, false},
+		{"not curved", &curvedEdge{routedEdge{waypoints: wps}, false}, false},
+		{"curved", &curvedEdge{routedEdge{waypoints: wps}, true}, true},
+		{"single curve", &curvedEdge{routedEdge{waypoints: wps[:2]}, true}, true},
+		{"partial curve", &curvedEdge{routedEdge{waypoints: wps[:3]}, true}, false},
+	}
+	for _, tc := range tcs {
+		if _, got := CurveWaypoints(tc.e); got != tc.want {
+			t.Errorf("%s: CurveWaypoints() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLayoutBounds(t *testing.T) {
+	l := NewLayout()
+	a, b := NewSNode("a", ""), NewSNode("b", "")
+	a.AppendSPad("", SideRight, 0)
+	b.AppendSPad("", SideLeft, 0)
+	l.MoveNode(a, 100, 100)
+	l.MoveNode(b, 500, 100)
+
+	check := func(name string, gotMin, gotMax, wantMin, wantMax [2]float64) {
+		t.Helper()
+		if gotMin != wantMin || gotMax != wantMax {
+			t.Errorf("%s = (%v, %v), want (%v, %v)", name, gotMin, gotMax, wantMin, wantMax)
+		}
+	}
+
+	// Node a spans 0-200 by 40-160, and node b spans 400-600. The pad on
+	// node b protrudes 12.5 past its left edge.
+	min, max := l.Bounds()
+	check("Bounds()", min, max, [2]float64{0, 40}, [2]float64{600, 160})
+	min, max = l.BoundsOf(a)
+	check("BoundsOf(a)", min, max, [2]float64{0, 40}, [2]float64{212.5, 160})
+	min, max = l.BoundsOf(b)
+	check("BoundsOf(b)", min, max, [2]float64{387.5, 40}, [2]float64{600, 160})
+
+	// Moving the node responsible for the bounds inwards should shrink them.
+	l.MoveNode(b, 300, 100)
+	min, max = l.Bounds()
+	check("Bounds() after move", min, max, [2]float64{0, 40}, [2]float64{400, 160})
+
+	// Edge routes should be accounted for.
+	e := &routedEdge{SEdge: NewSEdge("", a.Pads()[0], b.Pads()[0]), waypoints: [][2]float64{{250, -100}}}
+	a.Pads()[0].ConnectTo(e)
+	b.Pads()[0].ConnectFrom(e)
+	l.RecomputePadPositions(a)
+	min, max = l.Bounds()
+	check("Bounds() with route", min, max, [2]float64{0, -100}, [2]float64{400, 160})
+
+	min, max, dl, err := l.DisplayList()
+	if err != nil {
+		t.Fatalf("DisplayList() failed: %v", err)
+	}
+	check("DisplayList() bounds", min, max, [2]float64{0, -100}, [2]float64{400, 160})
+	if len(dl) != 5 {
+		t.Errorf("len(DisplayList()) = %d, want 5", len(dl))
+	}
+
+	// Changes to the route should be reflected once the layout is told.
+	e.waypoints = [][2]float64{{250, 300}}
+	l.RecomputeRoute(e)
+	min, max = l.Bounds()
+	check("Bounds() after route change", min, max, [2]float64{0, 40}, [2]float64{400, 300})
+
+	l.DeleteNode(a)
+	min, max = l.Bounds()
+	check("Bounds() after delete", min, max, [2]float64{187.5, 40}, [2]float64{400, 160})
+}
+
+func TestAutoPositionedPads(t *testing.T) {
+	l := NewLayout()
+	a, b, c := NewSNode("a", ""), NewSNode("b", ""), NewSNode("c", "")
+	out1, out2 := NewSPad("", a, SideLeft, 0), NewSPad("", a, SideLeft, 0)
+	out1.SetAutoPositioned(true)
+	out2.SetAutoPositioned(true)
+	a.AppendPad(out1)
+	a.AppendPad(out2)
+	b.AppendSPad("", SideLeft, 0)
+	c.AppendSPad("", SideLeft, 0)
+
+	l.MoveNode(a, 0, 0)
+	l.MoveNode(b, 500, -100)
+	l.MoveNode(c, 500, 100)
+	if _, err := a.LinkPads(c, out1, c.Pads()[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.LinkPads(b, out2, b.Pads()[0]); err != nil {
+		t.Fatal(err)
+	}
+	l.RecomputePadPositions(a)
+
+	// Both pads should face right, with the pad linked to the upper node
+	// placed above the other.
+	check := func(p *SPad, wantSide NodeSide, wantAmt float64) {
+		t.Helper()
+		if side, amt := p.Positioning(); side != wantSide || math.Abs(amt-wantAmt) > 1e-9 {
+			t.Errorf("Positioning() = (%v,%v), want (%v,%v)", side, amt, wantSide, wantAmt)
+		}
+	}
+	check(out2, SideRight, -1.0/3)
+	check(out1, SideRight, 1.0/3)
+	if x, y := l.Pad(out2).Pos(); x != 100 || math.Abs(y+20) > 1e-9 {
+		t.Errorf("pad position = (%v,%v), want (100,-20)", x, y)
+	}
+
+	// Dragging the linked nodes should move the pads with them.
+	l.MoveNode(b, 0, -500)
+	check(out2, SideTop, 0)
+	check(out1, SideRight, 0)
+	l.MoveNode(c, -500, 0)
+	check(out1, SideLeft, 0)
+
+	// Pads should keep their side once they are no longer linked.
+	l.DeleteNode(c)
+	check(out1, SideLeft, 0)
+	check(out2, SideTop, 0)
+}
+
+// TestAutoPositionedPadsConcurrent checks the positioning of pads can be
+// read while other goroutines move nodes, and so reposition the pads. It is
+// most useful when run with -race.
+func TestAutoPositionedPadsConcurrent(t *testing.T) {
+	l := NewLayout()
+	a, b := NewSNode("a", ""), NewSNode("b", "")
+	auto := NewSPad("", a, SideLeft, 0)
+	auto.SetAutoPositioned(true)
+	a.AppendPad(auto)
+	b.AppendSPad("", SideLeft, 0)
+	if _, err := a.LinkPads(b, auto, b.Pads()[0]); err != nil {
+		t.Fatal(err)
+	}
+	l.MoveNode(a, 0, 0)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 2; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				l.MoveNode(b, float64((i%2)*1000-500), float64(g*100))
+			}
+		}(g)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			auto.Positioning()
+			l.Pad(auto).Pos()
+		}
+	}
+}
+
+func TestAutoPositionedPadsAvoidFixed(t *testing.T) {
+	l := NewLayout()
+	a, b := NewSNode("a", ""), NewSNode("b", "")
+	auto := NewSPad("", a, SideLeft, 0)
+	auto.SetAutoPositioned(true)
+	a.AppendSPad("", SideRight, -0.5)
+	a.AppendPad(auto)
+	b.AppendSPad("", SideLeft, 0)
+
+	l.MoveNode(a, 0, 0)
+	l.MoveNode(b, 500, 0)
+	if _, err := a.LinkPads(b, auto, b.Pads()[0]); err != nil {
+		t.Fatal(err)
+	}
+	l.RecomputePadPositions(a)
+
+	// The fixed pad takes the upper of the two slots on the right side.
+	if side, amt := auto.Positioning(); side != SideRight || math.Abs(amt-1.0/3) > 1e-9 {
+		t.Errorf("Positioning() = (%v,%v), want (%v,%v)", side, amt, SideRight, 1.0/3)
+	}
+}
+
+func TestLayoutGeneration(t *testing.T) {
+	l := NewLayout()
+	a := NewSNode("a", "")
+	gen := l.Generation()
+
+	for _, step := range []struct {
+		name string
+		fn   func()
+	}{
+		{"MoveNode() of new node", func() { l.MoveNode(a, 100, 100) }},
+		{"MoveNode()", func() { l.MoveNode(a, 200, 100) }},
+		{"RecomputePadPositions()", func() { l.RecomputePadPositions(a) }},
+		{"DeleteNode()", func() { l.DeleteNode(a) }},
+	} {
+		step.fn()
+		if next := l.Generation(); next <= gen {
+			t.Errorf("Generation() after %s = %d, want > %d", step.name, next, gen)
+		} else {
+			gen = next
+		}
+	}
+
+	l.Bounds()
+	l.Dump()
+	if next := l.Generation(); next != gen {
+		t.Errorf("Generation() after reads = %d, want %d", next, gen)
+	}
+}
@@ -0,0 +1,14 @@
+package flow
+
+import "unicode/utf8"
+
+// TextMeasurer computes the width and height of a line of text, drawn
+// at the given font size.
+type TextMeasurer func(text string, fontSize float64) (w, h float64)
+
+// EstimateText is a TextMeasurer which estimates the size of text from the
+// number of characters in it, for use when no font is available. The
+// flow/gofont package measures text in the Go regular font instead.
+func EstimateText(text string, fontSize float64) (float64, float64) {
+	return 0.6 * fontSize * float64(utf8.RuneCountInString(text)), 1.2 * fontSize
+}
@@ -0,0 +1,106 @@
+package flow
+
+// Snapshot is a read-only copy of the state of a layout at a point in time.
+// Changes to the layout after the snapshot was taken are not reflected in
+// the snapshot, including changes to the edges between nodes, and a
+// snapshot is safe for use from any goroutine.
+//
+// The NodeLayout and PadLayout objects returned from a snapshot are owned by
+// the snapshot, and must not be modified.
+type Snapshot struct {
+	l *Layout
+	g *graph
+}
+
+// Snapshot returns a point-in-time copy of the layout. This allows display
+// lists to be computed or positions to be serialized off the main thread,
+// while edits to the layout continue.
+func (fl *Layout) Snapshot() *Snapshot {
+	g := fl.captureAll()
+
+	fl.mu.RLock()
+	c := &Layout{
+		allNodes:    make(map[string]Node, len(fl.allNodes)),
+		nodes:       make(map[string]*NodeLayout, len(fl.nodes)),
+		pads:        make(map[string]*PadLayout, len(fl.pads)),
+		seq:         make(map[string]uint64, len(fl.seq)),
+		nextSeq:     fl.nextSeq,
+		roots:       make(map[string]struct{}, len(fl.roots)),
+		extents:     make(map[string]bounds, len(fl.extents)),
+		b:           fl.b,
+		boundsDirty: fl.boundsDirty,
+		gen:         fl.gen,
+	}
+	for nID, n := range fl.allNodes {
+		c.allNodes[nID] = n
+	}
+	for nID, seq := range fl.seq {
+		c.seq[nID] = seq
+	}
+	for nID := range fl.roots {
+		c.roots[nID] = struct{}{}
+	}
+	for nID, e := range fl.extents {
+		c.extents[nID] = e
+	}
+	for nID, nl := range fl.nodes {
+		c.nodes[nID] = &NodeLayout{X: nl.X, Y: nl.Y}
+	}
+	for pID, pl := range fl.pads {
+		c.pads[pID] = &PadLayout{X: pl.X, Y: pl.Y}
+	}
+	fl.mu.RUnlock()
+
+	// Make sure the position of every node and pad is known, so the
+	// snapshot is never modified after this point.
+	c.addDiscovered(g)
+	c.bounds()
+	for _, nl := range c.nodes {
+		nl.mu = nil
+	}
+	for _, pl := range c.pads {
+		pl.mu = nil
+	}
+	return &Snapshot{l: c, g: g}
+}
+
+// Node returns the position of a node at the time of the snapshot, or nil
+// if the node was not part of the layout.
+func (s *Snapshot) Node(n Node) *NodeLayout {
+	return s.l.nodes[n.NodeID()]
+}
+
+// Pad returns the position of a pad at the time of the snapshot, or nil
+// if the pad was not part of the layout.
+func (s *Snapshot) Pad(p Pad) *PadLayout {
+	return s.l.pads[p.PadID()]
+}
+
+// Dump returns a list of all nodes and their positions at the time of
+// the snapshot.
+func (s *Snapshot) Dump() []NodePosition {
+	return s.l.Dump()
+}
+
+// Components returns the connected components of the layout at the time of
+// the snapshot.
+func (s *Snapshot) Components() []Component {
+	return publicComponents(s.l.components(s.g))
+}
+
+// Bounds returns the bounds of the layout at the time of the snapshot.
+func (s *Snapshot) Bounds() (min, max [2]float64) {
+	return s.l.b.minMax()
+}
+
+// BoundsOf returns the bounds of the given nodes at the time of the
+// snapshot.
+func (s *Snapshot) BoundsOf(nodes ...Node) (min, max [2]float64) {
+	return s.l.BoundsOf(nodes...)
+}
+
+// DisplayList returns a list of drawing commands for rendering the layout
+// as it was at the time of the snapshot.
+func (s *Snapshot) DisplayList() (min, max [2]float64, dl []DrawCommand, err error) {
+	return s.l.displayList(s.g)
+}
@@ -16,6 +16,11 @@ func (sn *SNode) NodeHeadline() string {
 	return sn.Headline
 }
 
+// SetHeadline implements flowui.EditableHeadline.
+func (sn *SNode) SetHeadline(hl string) {
+	sn.Headline = hl
+}
+
 func (sn *SNode) Size() (float64, float64) {
 	return 200, 120
 }
@@ -30,13 +35,14 @@ func (sn *SNode) AppendPad(pad Pad) {
 
 // LinkPads implements flowui.UserLinkable.
 func (sn *SNode) LinkPads(toNode Node, fromPad, toPad Pad) (Edge, error) {
-	for _, e := range append(fromPad.StartEdges(), fromPad.EndEdges()...) {
-		switch {
-		case e.From() == fromPad && e.To() == toPad:
-			return nil, ErrAlreadyLinked
-		case e.From() == toPad && e.To() == fromPad:
-			return nil, ErrAlreadyLinked
-		}
+	return linkPads(fromPad, toPad)
+}
+
+// linkPads connects two pads with a new SEdge, unless they are already
+// linked.
+func linkPads(fromPad, toPad Pad) (Edge, error) {
+	if len(EdgesBetween(fromPad, toPad)) > 0 {
+		return nil, ErrAlreadyLinked
 	}
 	edge := NewSEdge("", fromPad, toPad)
 	if err := fromPad.ConnectTo(edge); err != nil {
@@ -1,10 +1,17 @@
 package flow
 
+import "sync"
+
 type SPad struct {
-	id      string
+	id     string
+	parent Node
+
+	// mu guards the positioning of the pad, which the layout may set from
+	// any goroutine moving nodes.
+	mu      sync.RWMutex
 	side    NodeSide
 	sideAmt float64
-	parent  Node
+	auto    bool
 
 	startEdges []Edge
 	endEdges   []Edge
@@ -78,9 +85,33 @@ func (sp *SPad) Parent() Node {
 }
 
 func (sp *SPad) Positioning() (NodeSide, float64) {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
 	return sp.side, sp.sideAmt
 }
 
+// SetAutoPositioned sets whether the layout should choose the side and
+// offset of the pad, based on the position of the nodes it connects to.
+func (sp *SPad) SetAutoPositioned(auto bool) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.auto = auto
+}
+
+// AutoPositioned implements AutoPositionedPad.
+func (sp *SPad) AutoPositioned() bool {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	return sp.auto
+}
+
+// SetPositioning implements AutoPositionedPad.
+func (sp *SPad) SetPositioning(side NodeSide, sideAmt float64) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.side, sp.sideAmt = side, sideAmt
+}
+
 func (sp *SPad) PadColor() (float64, float64, float64) {
 	return sp.r, sp.g, sp.b
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/twitchyliquid64/diagg/flow"
 	"github.com/twitchyliquid64/diagg/flowui/render"
@@ -61,15 +63,27 @@ func (n *AddNode) NodeIcon() *gdk.Pixbuf {
 	return n.img
 }
 
+// Tooltip implements flowui.Tooltipped.
+func (n *AddNode) Tooltip() (string, bool) {
+	return "<b>Adder</b>\nSums its two inputs.", true
+}
+
+// CanLink implements flowui.LinkChecker. The output of the adder can only
+// be linked to inputs, which are pads on the left of a node.
+func (n *AddNode) CanLink(fromPad, toPad flow.Pad) error {
+	if fromPad != n.out {
+		return nil
+	}
+	if side, _ := toPad.Positioning(); side != flow.SideLeft {
+		return errors.New("the output can only be linked to an input")
+	}
+	return nil
+}
+
 // LinkPads implements flowui.UserLinkable.
 func (n *AddNode) LinkPads(toNode flow.Node, fromPad, toPad flow.Pad) (flow.Edge, error) {
-	for _, e := range append(fromPad.StartEdges(), fromPad.EndEdges()...) {
-		switch {
-		case e.From() == fromPad && e.To() == toPad:
-			return nil, flow.ErrAlreadyLinked
-		case e.From() == toPad && e.To() == fromPad:
-			return nil, flow.ErrAlreadyLinked
-		}
+	if len(flow.EdgesBetween(fromPad, toPad)) > 0 {
+		return nil, flow.ErrAlreadyLinked
 	}
 	edge := flow.NewSEdge("", fromPad, toPad)
 	if err := fromPad.ConnectTo(edge); err != nil {
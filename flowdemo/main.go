@@ -4,10 +4,12 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
-	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 	"github.com/twitchyliquid64/diagg/flow"
+	"github.com/twitchyliquid64/diagg/flow/gofont"
 	ui "github.com/twitchyliquid64/diagg/flowui"
 	"github.com/twitchyliquid64/diagg/flowui/overlays"
 )
@@ -18,8 +20,6 @@ type Win struct {
 	tlBox *gtk.Box
 	tools *overlays.ToolOverlay
 
-	selected flow.Node
-
 	fcv    *ui.FlowchartView
 	canvas *gtk.DrawingArea
 	status *gtk.Label
@@ -53,51 +53,64 @@ func (w *Win) build() error {
 	}
 	w.fcv = fcv
 	w.fcv.AddOverlay(w.tools)
+	w.fcv.AddOverlay(overlays.NewMinimap(w.fcv, 200, 150))
 	w.fcv.SetDoubleClickCallback(func(obj interface{}, x, y float64) {
 		fmt.Printf("double-click: %v at (%v,%v)\n", obj, x, y)
 	})
+	w.fcv.SetContextMenuProvider(w.contextMenu)
+	if err := w.fcv.SetDropTargets([]ui.DropTarget{{Target: "text/plain"}}, w.onDrop); err != nil {
+		return err
+	}
 	w.canvas = fcvRoot
 
 	if w.status, err = gtk.LabelNew("Nothing selected"); err != nil {
 		return err
 	}
 
-	fcvRoot.Connect("flow-selection", w.onFlowSelect)
+	w.fcv.SetSelectionCallback(w.onFlowSelect)
+	w.fcv.SetErrorCallback(func(err error) {
+		w.status.SetText(fmt.Sprintf("Error: %v", err))
+	})
 
 	w.tlBox.Add(w.status)
 	w.tlBox.Add(fcvRoot)
 	w.win.Add(w.tlBox)
-	return w.setupKeyBindings()
+	return nil
 }
 
-func (w *Win) setupKeyBindings() error {
-	// TODO: Refactor this into some configurable mapping.
-	w.win.Connect("key-press-event", func(win *gtk.Window, ev *gdk.Event) {
-		keyEvent := &gdk.EventKey{Event: ev}
-		if w.tools.HandleKeypress(keyEvent) {
-			w.canvas.QueueDraw()
+func (w *Win) contextMenu(target interface{}, x, y float64) (*glib.MenuModel, []ui.MenuAction) {
+	switch t := target.(type) {
+	case flow.Node:
+		return nil, []ui.MenuAction{
+			{Label: "Delete node", Activate: func() { w.fcv.DeleteNode(t) }},
 		}
-		if keyEvent.KeyVal() == gdk.KEY_Delete && w.selected != nil {
-			w.fcv.DeleteNode(w.selected)
-			w.selected = nil
-			w.status.SetText("Nothing selected")
+	case flow.Edge:
+		return nil, []ui.MenuAction{
+			{Label: "Delete link", Activate: func() { w.fcv.DeleteEdge(t) }},
 		}
-	})
-	return nil
+	}
+	return nil, nil
 }
 
-func (w *Win) onFlowSelect() {
-	sel := w.fcv.GetSelection()
-	if sel == nil {
-		w.status.SetText("Nothing selected")
-	} else {
-		w.status.SetText(fmt.Sprintf("Selected %T: %+v", sel, sel))
+// onDrop creates a node from text dropped onto the flowchart.
+func (w *Win) onDrop(target string, data []byte, x, y float64) {
+	n := flow.NewAutoSizeNode(strings.TrimSpace(string(data)), "", w.fcv.Layout(), gofont.Measure)
+	n.AppendSPad("", flow.SideLeft, 0.5)
+	n.AppendSPad("", flow.SideRight, 0.5)
+	w.fcv.Layout().MoveNode(n, x, y)
+	if err := w.fcv.Rebuild(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to add dropped node: %v\n", err)
 	}
+}
 
-	if n, ok := sel.(flow.Node); ok {
-		w.selected = n
-	} else {
-		w.selected = nil
+func (w *Win) onFlowSelect(sel []interface{}) {
+	switch len(sel) {
+	case 0:
+		w.status.SetText("Nothing selected")
+	case 1:
+		w.status.SetText(fmt.Sprintf("Selected %T: %+v", sel[0], sel[0]))
+	default:
+		w.status.SetText(fmt.Sprintf("Selected %d elements", len(sel)))
 	}
 }
 
@@ -133,6 +146,7 @@ func makeWin() (*Win, error) {
 		return nil, err
 	}
 	w.win.ShowAll()
+	w.canvas.GrabFocus()
 	return w, nil
 }
 
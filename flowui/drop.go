@@ -0,0 +1,62 @@
+package flowui
+
+import (
+	"errors"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// DropTarget describes a type of data which can be dragged onto the
+// flowchart from other widgets or applications.
+type DropTarget struct {
+	// Target is the name of the data type, such as "text/plain",
+	// "text/uri-list", or an application-specific name.
+	Target string
+	// Flags restrict where the data may be dragged from, such as
+	// gtk.TARGET_SAME_APP.
+	Flags gtk.TargetFlags
+}
+
+// DropCallback is invoked when data is dropped onto the flowchart. The
+// target is the name of the DropTarget the data was provided as, and x & y
+// are the flowchart coordinates of the drop.
+type DropCallback func(target string, data []byte, x, y float64)
+
+// SetDropTargets makes the flowchart accept drops of the given types of
+// data, invoking cb with the dropped data. At least one target must be
+// provided.
+func (fcv *FlowchartView) SetDropTargets(targets []DropTarget, cb DropCallback) error {
+	if len(targets) == 0 {
+		return errors.New("no drop targets provided")
+	}
+	entries := make([]gtk.TargetEntry, len(targets))
+	for i, t := range targets {
+		// The index of the target is used as its info, to identify the
+		// target when data is received.
+		e, err := gtk.TargetEntryNew(t.Target, t.Flags, uint(i))
+		if err != nil {
+			return err
+		}
+		entries[i] = *e
+	}
+
+	fcv.dropTargets, fcv.dropCB = targets, cb
+	fcv.da.DragDestSet(gtk.DEST_DEFAULT_ALL, entries, gdk.ACTION_COPY)
+	if !fcv.dropConnected {
+		fcv.da.Connect("drag-data-received", fcv.onDragDataReceived)
+		fcv.dropConnected = true
+	}
+	return nil
+}
+
+func (fcv *FlowchartView) onDragDataReceived(da *gtk.DrawingArea, ctx *gdk.DragContext, x, y int, data uintptr, info uint, time uint) {
+	if fcv.dropCB == nil || int(info) >= len(fcv.dropTargets) {
+		return
+	}
+	// The selection data is only valid during the signal, so it is copied.
+	payload := append([]byte(nil), gtk.GetData(data)...)
+	tp := fcv.drawCoordsToFlow(float64(x), float64(y))
+	fcv.dropCB(fcv.dropTargets[info].Target, payload, tp.X, tp.Y)
+	fcv.da.QueueDraw()
+}
@@ -0,0 +1,181 @@
+package flowui
+
+import (
+	"math"
+
+	"github.com/gotk3/gotk3/cairo"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/twitchyliquid64/diagg/hit"
+)
+
+// minGridSpacing is the smallest distance in pixels between drawn grid
+// lines. When zoomed out further, only every second, fourth, etc line is
+// drawn.
+const minGridSpacing = 8
+
+// GridStyle describes how the grid is drawn behind the flowchart.
+type GridStyle uint8
+
+// Valid GridStyle values.
+const (
+	// GridHidden draws no grid.
+	GridHidden GridStyle = iota
+	// GridDots draws a dot at each grid intersection.
+	GridDots
+	// GridLines draws the grid as lines.
+	GridLines
+)
+
+// GridSettings describes the grid nodes are positioned on.
+type GridSettings struct {
+	// Size is the distance between grid lines, in flowchart coordinates.
+	// Nodes are snapped to the center of the cells between grid lines.
+	Size float64
+	// Snap enables snapping nodes to the grid as they are moved.
+	Snap bool
+	// SnapToPads aligns the pads of a node being moved with nearby pads on
+	// other nodes, taking precedence over the grid. It is off by default.
+	SnapToPads bool
+	// Style controls how the grid is drawn.
+	Style GridStyle
+	// NoSnapMods are the modifiers which temporarily disable snapping
+	// while held during a drag.
+	NoSnapMods gdk.ModifierType
+}
+
+// DefaultGridSettings returns the default grid settings.
+func DefaultGridSettings() GridSettings {
+	return GridSettings{
+		Size:       16,
+		Snap:       true,
+		Style:      GridDots,
+		NoSnapMods: gdk.GDK_SHIFT_MASK,
+	}
+}
+
+// snapToGrid returns the center of the grid cell containing the position.
+func (gs GridSettings) snapToGrid(x, y float64) (float64, float64) {
+	if gs.Size <= 0 {
+		return x, y
+	}
+	x = math.Floor(x/gs.Size)*gs.Size + gs.Size/2
+	y = math.Floor(y/gs.Size)*gs.Size + gs.Size/2
+	return x, y
+}
+
+// snapPosition returns the position a node should be moved to, when the
+// user moves it to the given position.
+func (fcv *FlowchartView) snapPosition(rn *rectNode, x, y float64, mods gdk.ModifierType) (float64, float64) {
+	if fcv.grid.NoSnapMods != 0 && mods&fcv.grid.NoSnapMods != 0 {
+		return x, y
+	}
+
+	snappedX, snappedY := false, false
+	if fcv.grid.SnapToPads {
+		x, y, snappedX, snappedY = fcv.snapToPads(rn, x, y)
+	}
+	if fcv.grid.Snap {
+		gx, gy := fcv.grid.snapToGrid(x, y)
+		if !snappedX {
+			x = gx
+		}
+		if !snappedY {
+			y = gy
+		}
+	}
+	return x, y
+}
+
+// snapToPads adjusts the position of a node such that its pads line up
+// horizontally or vertically with the nearest pads on nodes which are not
+// being moved.
+func (fcv *FlowchartView) snapToPads(rn *rectNode, x, y float64) (float64, float64, bool, bool) {
+	var (
+		nx, ny       = rn.Pos()
+		bestX        = fcv.grid.Size / 2
+		bestY        = fcv.grid.Size / 2
+		dx, dy       float64
+		snapX, snapY bool
+	)
+	if bestX <= 0 {
+		return x, y, false, false
+	}
+
+	for _, p := range rn.N.Pads() {
+		pad, ok := fcv.model.nodeState[p.PadID()].(*circPad)
+		if !ok {
+			continue
+		}
+		px, py := pad.Pos()
+		px, py = px-nx+x, py-ny+y
+
+		near := fcv.model.NearestPad(hit.Point{X: px, Y: py}, 4*fcv.grid.Size, func(other *circPad) bool {
+			_, moving := fcv.groupStart[fcv.nodeTarget(other)]
+			return other.P.Parent() != rn.N && !moving
+		})
+		if near == nil {
+			continue
+		}
+		ox, oy := near.Pos()
+		if d := math.Abs(ox - px); d < bestX {
+			bestX, dx, snapX = d, ox-px, true
+		}
+		if d := math.Abs(oy - py); d < bestY {
+			bestY, dy, snapY = d, oy-py, true
+		}
+	}
+	return x + dx, y + dy, snapX, snapY
+}
+
+// nodeTarget returns the hit target of the node which owns the pad.
+func (fcv *FlowchartView) nodeTarget(p *circPad) *rectNode {
+	rn, _ := fcv.model.nodeState[p.P.Parent().NodeID()].(*rectNode)
+	return rn
+}
+
+// drawGrid draws the grid behind the flowchart, in drawing area coordinates.
+func (fcv *FlowchartView) drawGrid(cr *cairo.Context) {
+	if fcv.grid.Style == GridHidden || fcv.grid.Size <= 0 || fcv.zoom <= 0 {
+		return
+	}
+	spacing := fcv.grid.Size * fcv.zoom
+	for spacing < minGridSpacing {
+		spacing *= 2
+	}
+
+	var (
+		startX = math.Mod(fcv.offsetX, spacing)
+		startY = math.Mod(fcv.offsetY, spacing)
+		w, h   = float64(fcv.width), float64(fcv.height)
+	)
+	if startX < 0 {
+		startX += spacing
+	}
+	if startY < 0 {
+		startY += spacing
+	}
+
+	cr.Save()
+	defer cr.Restore()
+	cr.SetSourceRGB(0.2, 0.2, 0.2)
+	switch fcv.grid.Style {
+	case GridDots:
+		for x := startX; x < w; x += spacing {
+			for y := startY; y < h; y += spacing {
+				cr.Rectangle(x-0.75, y-0.75, 1.5, 1.5)
+			}
+		}
+		cr.Fill()
+	case GridLines:
+		cr.SetLineWidth(1)
+		for x := startX; x < w; x += spacing {
+			cr.MoveTo(math.Floor(x)+0.5, 0)
+			cr.LineTo(math.Floor(x)+0.5, h)
+		}
+		for y := startY; y < h; y += spacing {
+			cr.MoveTo(0, math.Floor(y)+0.5)
+			cr.LineTo(w, math.Floor(y)+0.5)
+		}
+		cr.Stroke()
+	}
+}
@@ -0,0 +1,231 @@
+package flowui
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/twitchyliquid64/diagg/flow"
+	"github.com/twitchyliquid64/diagg/hit"
+)
+
+// EditableHeadline describes nodes whose headline can be edited by the
+// user on the canvas, by double-clicking the node or pressing F2.
+type EditableHeadline interface {
+	flow.Node
+	NodeHeadline() string
+	SetHeadline(hl string)
+}
+
+// HeadlineEdit describes a change to the headline of a node made by the
+// user.
+type HeadlineEdit struct {
+	Node     EditableHeadline
+	Old, New string
+}
+
+const (
+	// headlineEditHeight is the height of the band at the top of a node
+	// which its headline is drawn in, and the headline is edited over.
+	headlineEditHeight = flow.HeadlineFontSize + 10
+	// headlineEditInset is the distance of the headline from the left of
+	// the node.
+	headlineEditInset = 7
+	// popoverTail is the height of the arrow GTK draws between a popover
+	// and the area it points to. Headline editors are styled so that the
+	// arrow is not visible, but the popover is still offset by it.
+	popoverTail = 8
+)
+
+// headlineEditor is a headline being edited, in an entry placed over the
+// headline of the node. The entry is shown in a popover styled to be
+// invisible, as the canvas cannot contain other widgets. Using a real entry
+// provides input methods, compose sequences, the clipboard and selection.
+type headlineEditor struct {
+	node  EditableHeadline
+	pop   *gtk.Popover
+	entry *gtk.Entry
+}
+
+// close hides and destroys the popover of the editor, returning focus to
+// the canvas.
+func (ed *headlineEditor) close(da *gtk.DrawingArea) {
+	ed.pop.Popdown()
+	ed.pop.Destroy()
+	da.GrabFocus()
+}
+
+// beginHeadlineEdit starts editing the headline of the node, returning
+// false if the node does not support it.
+func (fcv *FlowchartView) beginHeadlineEdit(rn *rectNode) bool {
+	n, ok := rn.N.(EditableHeadline)
+	if !ok {
+		return false
+	}
+	fcv.commitHeadlineEdit()
+
+	ed, err := fcv.newHeadlineEditor(rn, n)
+	if err != nil {
+		fcv.reportError(err)
+		return false
+	}
+	fcv.editor = ed
+	fcv.lmc.dragging = false
+	fcv.lmc.target = rn
+	fcv.setSelection([]hit.TestableObj{rn})
+	fcv.selectionChanged()
+	fcv.hideTooltip()
+
+	ed.pop.Popup()
+	ed.entry.GrabFocus()
+	fcv.da.QueueDraw()
+	return true
+}
+
+// newHeadlineEditor builds a popover containing an entry for the headline of
+// the node, which covers the headline as drawn on the canvas at the current
+// zoom. The edit is committed when enter is pressed or the popover is
+// dismissed, and cancelled by escape.
+func (fcv *FlowchartView) newHeadlineEditor(rn *rectNode, n EditableHeadline) (*headlineEditor, error) {
+	pop, err := gtk.PopoverNew(fcv.da)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := gtk.EntryNew()
+	if err != nil {
+		pop.Destroy()
+		return nil, err
+	}
+	hl := n.NodeHeadline()
+	entry.SetText(hl)
+	// The entry is sized to the headline below, rather than to fit a
+	// number of characters.
+	entry.SetWidthChars(1)
+	entry.SelectRegion(0, -1)
+	entry.Connect("activate", func() {
+		fcv.commitHeadlineEdit()
+	})
+	entry.Connect("key-press-event", func(e *gtk.Entry, event *gdk.Event) bool {
+		if (&gdk.EventKey{Event: event}).KeyVal() == gdk.KEY_Escape {
+			fcv.cancelHeadlineEdit()
+			return true
+		}
+		return false
+	})
+	entry.Show()
+	pop.Add(entry)
+	pop.Connect("closed", func() {
+		fcv.commitHeadlineEdit()
+	})
+
+	// The popover points at the area just above the headline, so that the
+	// entry below its arrow lands on the headline, and the entry is sized
+	// to the headline.
+	var (
+		x, y = rn.Pos()
+		w, h = rn.N.Size()
+		sw   = int(math.Max(1, w*fcv.zoom))
+		sh   = int(math.Max(1, headlineEditHeight*fcv.zoom))
+		rect gdk.Rectangle
+	)
+	rect.SetX(int((x-w/2)*fcv.zoom + fcv.offsetX))
+	rect.SetY(int((y-h/2)*fcv.zoom+fcv.offsetY) - popoverTail - 1)
+	rect.SetWidth(sw)
+	rect.SetHeight(1)
+	pop.SetPointingTo(rect)
+	pop.SetPosition(gtk.POS_BOTTOM)
+	entry.SetSizeRequest(sw, sh)
+	if err := styleHeadlineEditor(pop, entry, fcv.zoom); err != nil {
+		pop.Destroy()
+		return nil, err
+	}
+
+	return &headlineEditor{node: n, pop: pop, entry: entry}, nil
+}
+
+// styleHeadlineEditor hides the frame and arrow of the popover, and scales
+// the font and padding of the entry to match the headline at the given zoom.
+func styleHeadlineEditor(pop *gtk.Popover, entry *gtk.Entry, zoom float64) error {
+	css, err := gtk.CssProviderNew()
+	if err != nil {
+		return err
+	}
+	err = css.LoadFromData(fmt.Sprintf(`
+		popover {
+		    background: transparent;
+		    border: none;
+		    box-shadow: none;
+		    margin: 0;
+		    padding: 0;
+		}
+		entry {
+		    font-size: %.2fpx;
+		    min-height: 0;
+		    padding: 0 %.2fpx;
+		    border-radius: 0;
+		}
+	`, flow.HeadlineFontSize*zoom, headlineEditInset*zoom))
+	if err != nil {
+		return err
+	}
+
+	for _, w := range []*gtk.Widget{&pop.Widget, &entry.Widget} {
+		style, err := w.GetStyleContext()
+		if err != nil {
+			return err
+		}
+		style.AddProvider(css, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+	}
+	return nil
+}
+
+// editSelectedHeadline starts editing the headline of the most recently
+// selected node.
+func (fcv *FlowchartView) editSelectedHeadline() {
+	if rn, isNode := fcv.lmc.target.(*rectNode); isNode && fcv.isSelected(rn) {
+		fcv.beginHeadlineEdit(rn)
+	}
+}
+
+// commitHeadlineEdit applies the edited headline to the node, if a headline
+// is being edited.
+func (fcv *FlowchartView) commitHeadlineEdit() {
+	ed := fcv.editor
+	if ed == nil {
+		return
+	}
+	// The editor is cleared first, as closing the popover calls back into
+	// this method.
+	fcv.editor = nil
+	text, err := ed.entry.GetText()
+	ed.close(fcv.da)
+	fcv.da.QueueDraw()
+	if err != nil {
+		fcv.reportError(err)
+		return
+	}
+
+	change := HeadlineEdit{Node: ed.node, Old: ed.node.NodeHeadline(), New: text}
+	if change.New == change.Old {
+		return
+	}
+	ed.node.SetHeadline(change.New)
+	// The size of the node may have changed with its headline.
+	fcv.model.l.RecomputePadPositions(ed.node)
+	fcv.model.updateNodeHits(ed.node)
+
+	fcv.da.Emit("flow-headline-edited")
+	if fcv.headlineCB != nil {
+		fcv.headlineCB(change)
+	}
+}
+
+// cancelHeadlineEdit discards the headline being edited.
+func (fcv *FlowchartView) cancelHeadlineEdit() {
+	if ed := fcv.editor; ed != nil {
+		fcv.editor = nil
+		ed.close(fcv.da)
+		fcv.da.QueueDraw()
+	}
+}
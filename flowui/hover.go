@@ -0,0 +1,178 @@
+package flowui
+
+import (
+	"math"
+
+	"github.com/gotk3/gotk3/cairo"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/pango"
+	"github.com/twitchyliquid64/diagg/hit"
+)
+
+const (
+	// defaultTooltipDelay is the time in milliseconds the mouse must rest
+	// over an element before its tooltip is shown.
+	defaultTooltipDelay = 600
+	// tooltipOffset is the distance in pixels from the mouse to the tooltip.
+	tooltipOffset = 16
+	// tooltipPadding is the space in pixels around the text of a tooltip.
+	tooltipPadding = 6
+	// tooltipMaxWidth is the width in pixels at which tooltip text wraps.
+	tooltipMaxWidth = 320
+	// messageDuration is the time in milliseconds a message is shown for.
+	messageDuration = 2500
+)
+
+// Tooltipped describes nodes, pads or edges which show a tooltip when the
+// mouse rests over them.
+type Tooltipped interface {
+	// Tooltip returns the text of the tooltip, or an empty string if no
+	// tooltip should be shown. If markup is true, the text is interpreted
+	// as Pango markup.
+	Tooltip() (text string, markup bool)
+}
+
+// HoverCallback is invoked when the element under the mouse changes. The
+// target is the flow.Node, flow.Pad or flow.Edge under the mouse, or nil if
+// the mouse moved off all elements. x & y are the flowchart coordinates of
+// the mouse.
+type HoverCallback func(target interface{}, x, y float64)
+
+// tooltipState describes the tooltip of the hovered element, which is
+// drawn once the mouse has rested over the element for the tooltip delay.
+type tooltipState struct {
+	timer   glib.SourceHandle
+	visible bool
+	text    string
+	markup  bool
+	// Position of the mouse on the drawing area.
+	x, y float64
+}
+
+// updateHover tracks the element under the mouse, which is at the given
+// position on the drawing area.
+func (fcv *FlowchartView) updateHover(x, y float64) {
+	tp := fcv.drawCoordsToFlow(x, y)
+	target := fcv.model.HitTest(tp)
+	edge, _ := target.(*lineEdge)
+	fcv.setHoverEdge(edge)
+
+	if !fcv.tooltip.visible {
+		fcv.tooltip.x, fcv.tooltip.y = x, y
+	}
+	if target == fcv.hovered {
+		return
+	}
+	fcv.hovered = target
+	fcv.hideTooltip()
+	if fcv.hoverCB != nil {
+		fcv.hoverCB(flowObject(target), tp.X, tp.Y)
+	}
+
+	tt, ok := flowObject(target).(Tooltipped)
+	if !ok {
+		return
+	}
+	if fcv.tooltip.text, fcv.tooltip.markup = tt.Tooltip(); fcv.tooltip.text == "" {
+		return
+	}
+	fcv.tooltip.timer, _ = glib.TimeoutAdd(fcv.tooltipDelay, func() bool {
+		fcv.tooltip.timer = 0
+		fcv.tooltip.visible = true
+		fcv.da.QueueDraw()
+		return false
+	})
+}
+
+// clearHover forgets the hovered element, as the mouse has left the view
+// or is being used to drag.
+func (fcv *FlowchartView) clearHover() {
+	fcv.setHoverEdge(nil)
+	fcv.hideTooltip()
+	if fcv.hovered == nil {
+		return
+	}
+	fcv.hovered = nil
+	if fcv.hoverCB != nil {
+		fcv.hoverCB(nil, 0, 0)
+	}
+}
+
+// forgetHover clears the hover state if it refers to an element which is
+// being removed.
+func (fcv *FlowchartView) forgetHover(t hit.TestableObj) {
+	if fcv.hovered == t {
+		fcv.clearHover()
+	}
+}
+
+// showMessage shows text in a tooltip at the given position on the drawing
+// area, until the mouse moves to another element or the message times out.
+func (fcv *FlowchartView) showMessage(text string, x, y float64) {
+	fcv.updateHover(x, y)
+	fcv.hideTooltip()
+	fcv.tooltip.text, fcv.tooltip.markup = text, false
+	fcv.tooltip.x, fcv.tooltip.y = x, y
+	fcv.tooltip.visible = true
+	fcv.tooltip.timer, _ = glib.TimeoutAdd(messageDuration, func() bool {
+		fcv.tooltip.timer = 0
+		fcv.hideTooltip()
+		return false
+	})
+	fcv.da.QueueDraw()
+}
+
+func (fcv *FlowchartView) hideTooltip() {
+	if fcv.tooltip.timer != 0 {
+		glib.SourceRemove(fcv.tooltip.timer)
+		fcv.tooltip.timer = 0
+	}
+	if fcv.tooltip.visible {
+		fcv.tooltip.visible = false
+		fcv.da.QueueDraw()
+	}
+}
+
+// drawTooltip draws the visible tooltip near the mouse, in drawing area
+// coordinates.
+func (fcv *FlowchartView) drawTooltip(cr *cairo.Context) {
+	if !fcv.tooltip.visible {
+		return
+	}
+	layout := pango.CairoCreateLayout(cr)
+	layout.SetWidth(tooltipMaxWidth * pango.PANGO_SCALE)
+	layout.SetWrap(pango.WRAP_WORD)
+	if fcv.tooltip.markup {
+		layout.SetMarkup(fcv.tooltip.text, -1)
+	} else {
+		layout.SetText(fcv.tooltip.text, -1)
+	}
+	tw, th := layout.GetSize()
+	var (
+		w = float64(tw)/pango.PANGO_SCALE + 2*tooltipPadding
+		h = float64(th)/pango.PANGO_SCALE + 2*tooltipPadding
+		x = fcv.tooltip.x + tooltipOffset
+		y = fcv.tooltip.y + tooltipOffset
+	)
+	// Keep the tooltip within the drawing area, moving it to the other
+	// side of the mouse if necessary.
+	if x+w > float64(fcv.width) {
+		x = math.Max(0, fcv.tooltip.x-tooltipOffset-w)
+	}
+	if y+h > float64(fcv.height) {
+		y = math.Max(0, fcv.tooltip.y-tooltipOffset-h)
+	}
+
+	cr.Save()
+	defer cr.Restore()
+	cr.Rectangle(x, y, w, h)
+	cr.SetSourceRGBA(0.05, 0.05, 0.05, 0.92)
+	cr.FillPreserve()
+	cr.SetLineWidth(1)
+	cr.SetSourceRGB(0.4, 0.4, 0.4)
+	cr.Stroke()
+
+	cr.SetSourceRGB(0.95, 0.95, 0.95)
+	cr.MoveTo(x+tooltipPadding, y+tooltipPadding)
+	pango.CairoShowLayout(cr, layout)
+}
@@ -0,0 +1,196 @@
+package flowui
+
+import (
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/twitchyliquid64/diagg/hit"
+)
+
+// zoomStep is the amount the zoom changes by for each zoom key press.
+const zoomStep = 0.1
+
+// Action describes an operation on the flowchart which can be bound to a key.
+type Action uint8
+
+// Valid Action values.
+const (
+	ActionNone Action = iota
+	// ActionDelete removes the selected elements.
+	ActionDelete
+	// ActionNudgeLeft moves the selected nodes left by one grid position.
+	ActionNudgeLeft
+	// ActionNudgeRight moves the selected nodes right by one grid position.
+	ActionNudgeRight
+	// ActionNudgeUp moves the selected nodes up by one grid position.
+	ActionNudgeUp
+	// ActionNudgeDown moves the selected nodes down by one grid position.
+	ActionNudgeDown
+	// ActionNextNode selects the next node.
+	ActionNextNode
+	// ActionPrevNode selects the previous node.
+	ActionPrevNode
+	// ActionSelectAll selects all nodes.
+	ActionSelectAll
+	// ActionZoomIn zooms in around the center of the view.
+	ActionZoomIn
+	// ActionZoomOut zooms out around the center of the view.
+	ActionZoomOut
+	// ActionZoomToFit zooms to show the whole flowchart.
+	ActionZoomToFit
+	// ActionZoomToSelection zooms to show the selected elements.
+	ActionZoomToSelection
+	// ActionEditHeadline starts editing the headline of the selected node.
+	ActionEditHeadline
+)
+
+// keyMods are the modifiers considered when matching key bindings.
+const keyMods = gdk.GDK_SHIFT_MASK | gdk.GDK_CONTROL_MASK | gdk.GDK_MOD1_MASK
+
+// KeyBinding describes a key press. Key is a GDK keyval, such as
+// gdk.KEY_Delete, and Mods are the modifiers which must be held.
+type KeyBinding struct {
+	Key  uint
+	Mods gdk.ModifierType
+}
+
+// Keymap maps key presses to the actions they perform.
+type Keymap map[KeyBinding]Action
+
+// DefaultKeymap returns the default key bindings.
+func DefaultKeymap() Keymap {
+	return Keymap{
+		{Key: gdk.KEY_Delete}:    ActionDelete,
+		{Key: gdk.KEY_BackSpace}: ActionDelete,
+		{Key: gdk.KEY_Left}:      ActionNudgeLeft,
+		{Key: gdk.KEY_Right}:     ActionNudgeRight,
+		{Key: gdk.KEY_Up}:        ActionNudgeUp,
+		{Key: gdk.KEY_Down}:      ActionNudgeDown,
+		{Key: gdk.KEY_Tab}:       ActionNextNode,
+		{Key: gdk.KEY_ISO_Left_Tab, Mods: gdk.GDK_SHIFT_MASK}: ActionPrevNode,
+		{Key: gdk.KEY_a, Mods: gdk.GDK_CONTROL_MASK}:          ActionSelectAll,
+		{Key: gdk.KEY_plus}:                          ActionZoomIn,
+		{Key: gdk.KEY_equal}:                         ActionZoomIn,
+		{Key: gdk.KEY_KP_Add}:                        ActionZoomIn,
+		{Key: gdk.KEY_minus}:                         ActionZoomOut,
+		{Key: gdk.KEY_KP_Subtract}:                   ActionZoomOut,
+		{Key: gdk.KEY_0, Mods: gdk.GDK_CONTROL_MASK}: ActionZoomToFit,
+		{Key: gdk.KEY_f}:                             ActionZoomToSelection,
+		{Key: gdk.KEY_F2}:                            ActionEditHeadline,
+	}
+}
+
+// Lookup returns the action bound to the given key press. As some keys
+// can only be typed with Shift held, a binding without Shift matches if
+// there is no binding which includes it.
+func (km Keymap) Lookup(key uint, mods gdk.ModifierType) Action {
+	key, mods = gdk.KeyvalToLower(key), mods&keyMods
+	if a, ok := km[KeyBinding{Key: key, Mods: mods}]; ok {
+		return a
+	}
+	if mods&gdk.GDK_SHIFT_MASK != 0 {
+		return km[KeyBinding{Key: key, Mods: mods &^ gdk.GDK_SHIFT_MASK}]
+	}
+	return ActionNone
+}
+
+func (fcv *FlowchartView) onKeyPressEvent(area *gtk.DrawingArea, event *gdk.Event) bool {
+	evt := &gdk.EventKey{Event: event}
+	for _, o := range fcv.overlays {
+		if kh, ok := o.(KeyHandler); ok && kh.HandleKeypress(evt) {
+			fcv.da.QueueDraw()
+			return true
+		}
+	}
+
+	// When context menus are enabled, space is held to pan with the left
+	// mouse button.
+	if evt.KeyVal() == gdk.KEY_space && fcv.menuProvider != nil {
+		fcv.spaceHeld = true
+		return true
+	}
+
+	action := fcv.keymap.Lookup(evt.KeyVal(), gdk.ModifierType(evt.State()))
+	if action == ActionNone {
+		return false
+	}
+	if err := fcv.performAction(action); err != nil {
+		fcv.reportError(err)
+	}
+	fcv.da.QueueDraw()
+	return true
+}
+
+func (fcv *FlowchartView) onKeyReleaseEvent(area *gtk.DrawingArea, event *gdk.Event) bool {
+	evt := &gdk.EventKey{Event: event}
+	if evt.KeyVal() == gdk.KEY_space && fcv.spaceHeld {
+		fcv.spaceHeld = false
+		return true
+	}
+	return false
+}
+
+func (fcv *FlowchartView) performAction(action Action) error {
+	switch action {
+	case ActionDelete:
+		return fcv.DeleteSelection()
+	case ActionNudgeLeft:
+		fcv.nudgeSelection(-fcv.grid.Size, 0)
+	case ActionNudgeRight:
+		fcv.nudgeSelection(fcv.grid.Size, 0)
+	case ActionNudgeUp:
+		fcv.nudgeSelection(0, -fcv.grid.Size)
+	case ActionNudgeDown:
+		fcv.nudgeSelection(0, fcv.grid.Size)
+	case ActionNextNode:
+		fcv.cycleNodes(1)
+	case ActionPrevNode:
+		fcv.cycleNodes(-1)
+	case ActionSelectAll:
+		fcv.SelectAll()
+	case ActionZoomIn:
+		fcv.zoomAt(float64(fcv.width)/2, float64(fcv.height)/2, zoomStep)
+	case ActionZoomOut:
+		fcv.zoomAt(float64(fcv.width)/2, float64(fcv.height)/2, -zoomStep)
+	case ActionZoomToFit:
+		fcv.ZoomToFit(defaultFramePadding)
+	case ActionZoomToSelection:
+		fcv.ZoomToSelection()
+	case ActionEditHeadline:
+		fcv.editSelectedHeadline()
+	}
+	return nil
+}
+
+// nudgeSelection moves all selected nodes by the given offset.
+func (fcv *FlowchartView) nudgeSelection(dx, dy float64) {
+	for _, s := range fcv.selection {
+		if rn, isNode := s.(*rectNode); isNode {
+			x, y := fcv.model.TargetPos(rn)
+			fcv.model.MoveTarget(rn, x+dx, y+dy)
+		}
+	}
+}
+
+// cycleNodes selects the node the given number of positions after the most
+// recently selected node, wrapping around.
+func (fcv *FlowchartView) cycleNodes(step int) {
+	nodes := fcv.model.nodeTargets()
+	if len(nodes) == 0 {
+		return
+	}
+
+	next := 0
+	if step < 0 {
+		next = len(nodes) - 1
+	}
+	for i, n := range nodes {
+		if n == fcv.lmc.target {
+			next = ((i+step)%len(nodes) + len(nodes)) % len(nodes)
+			break
+		}
+	}
+
+	fcv.lmc.target = nodes[next]
+	fcv.setSelection([]hit.TestableObj{nodes[next]})
+	fcv.selectionChanged()
+}
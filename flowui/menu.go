@@ -0,0 +1,81 @@
+package flowui
+
+import (
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// MenuAction describes an entry in a context menu. An entry with an empty
+// label is shown as a separator.
+type MenuAction struct {
+	Label string
+	// Activate is invoked when the entry is chosen. Entries without an
+	// Activate function are shown, but cannot be chosen.
+	Activate func()
+}
+
+// ContextMenuProvider returns the context menu to show when the user
+// right-clicks on the flowchart. The target is the flow.Node, flow.Pad or
+// flow.Edge under the mouse, or nil if the user clicked on empty space, and
+// x & y are the flowchart coordinates of the click.
+//
+// If a menu model is returned, it is shown in a popover and its actions
+// are resolved against the action groups of the drawing area and its
+// ancestors. Otherwise, a menu is built from the returned actions. If
+// neither are returned, no menu is shown.
+type ContextMenuProvider func(target interface{}, x, y float64) (*glib.MenuModel, []MenuAction)
+
+// showContextMenu shows the context menu for the element at the given
+// position on the drawing area.
+func (fcv *FlowchartView) showContextMenu(event *gdk.Event, x, y float64) error {
+	tp := fcv.drawCoordsToFlow(x, y)
+	model, actions := fcv.menuProvider(flowObject(fcv.model.HitTest(tp)), tp.X, tp.Y)
+
+	switch {
+	case model != nil:
+		pop, err := gtk.PopoverNewFromModel(fcv.da, model)
+		if err != nil {
+			return err
+		}
+		var rect gdk.Rectangle
+		rect.SetX(int(x))
+		rect.SetY(int(y))
+		rect.SetWidth(1)
+		rect.SetHeight(1)
+		pop.SetPointingTo(rect)
+		pop.Popup()
+
+	case len(actions) > 0:
+		menu, err := gtk.MenuNew()
+		if err != nil {
+			return err
+		}
+		for _, a := range actions {
+			if a.Label == "" {
+				sep, err := gtk.SeparatorMenuItemNew()
+				if err != nil {
+					return err
+				}
+				menu.Append(sep)
+				continue
+			}
+
+			item, err := gtk.MenuItemNewWithLabel(a.Label)
+			if err != nil {
+				return err
+			}
+			if activate := a.Activate; activate != nil {
+				item.Connect("activate", func() { activate() })
+			} else {
+				item.SetSensitive(false)
+			}
+			menu.Append(item)
+		}
+		menu.ShowAll()
+		// Hold a reference to the menu while it is open.
+		fcv.menu = menu
+		menu.PopupAtPointer(event)
+	}
+	return nil
+}
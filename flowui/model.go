@@ -25,6 +25,9 @@ type Model struct {
 	displayList []flow.DrawCommand
 	// Maps node/pad ID to state.
 	nodeState map[string]modelNode
+	// Distance from an edge in flowchart coordinates which is considered
+	// to hit the edge.
+	edgeTolerance float64
 
 	// performance metrics
 	drawTime  averageMetric
@@ -32,26 +35,20 @@ type Model struct {
 	hitTime   averageMetric
 }
 
-func (m *Model) maybeUpdateMinMax(x, y float64) {
-	if x > m.nMax.X {
-		m.nMax.X = x
-	} else if x < m.nMin.X {
-		m.nMin.X = x
-	}
-	if y > m.nMax.Y {
-		m.nMax.Y = y
-	} else if y < m.nMin.Y {
-		m.nMin.Y = y
-	}
+// updateMinMax updates the bounds of the flowchart from the layout.
+func (m *Model) updateMinMax() {
+	min, max := m.l.Bounds()
+	m.nMin, m.nMax = hit.Point{X: min[0], Y: min[1]}, hit.Point{X: max[0], Y: max[1]}
 }
 
 func (m *Model) MoveTarget(t hit.TestableObj, x, y float64) {
 	switch t := t.(type) {
 	case *rectNode:
-		m.maybeUpdateMinMax(x, y)
 		m.l.MoveNode(t.N.(flow.Node), x, y)
-	case *circPad:
-		// Not possible to move a pad.
+		m.updateMinMax()
+		m.updateNodeHits(t.N)
+	case *circPad, *lineEdge:
+		// Not possible to move a pad or edge.
 	default:
 		panic("cannot handle type")
 	}
@@ -63,6 +60,8 @@ func (m *Model) TargetPos(t hit.TestableObj) (x, y float64) {
 		return m.l.Node(t.N.(flow.Node)).Pos()
 	case *circPad:
 		return m.l.Pad(t.P.(flow.Pad)).Pos()
+	case *lineEdge:
+		return t.Pos()
 	default:
 		panic("cannot handle type")
 	}
@@ -88,52 +87,119 @@ func (m *Model) buildDrawList() error {
 }
 
 func (m *Model) insertNodeObj(c flow.DrawNodeCmd, area *hit.Area) {
-	var (
-		x, y     = c.Layout.Pos()
-		w, h     = c.Node.Size()
-		min, max = hit.Point{X: x - w/2, Y: y - h/2}, hit.Point{X: x + w/2, Y: y + h/2}
-		nID      = c.Node.NodeID()
-	)
-
+	nID := c.Node.NodeID()
 	sn, ok := m.nodeState[nID]
 	if !ok {
 		sn = &rectNode{N: c.Node, Layout: c.Layout}
 		m.nodeState[nID] = sn
 	}
+	min, max := sn.HitBounds()
 	area.Add(min, max, sn)
 }
 
 func (m *Model) insertPadObj(c flow.DrawPadCmd, area *hit.Area) {
-	var (
-		x, y     = c.Layout.Pos()
-		dia, _   = c.Pad.Size()
-		min, max = hit.Point{X: x - dia/2, Y: y - dia/2}, hit.Point{X: x + dia/2, Y: y + dia/2}
-		pID      = c.Pad.PadID()
-	)
-
+	pID := c.Pad.PadID()
 	sn, ok := m.nodeState[pID]
 	if !ok {
 		sn = &circPad{P: c.Pad, Layout: c.Layout}
 		m.nodeState[pID] = sn
 	}
+	min, max := sn.HitBounds()
 	area.Add(min, max, sn)
 }
 
+func (m *Model) insertEdgeObj(c flow.DrawEdgeCmd, area *hit.Area) {
+	eID := c.Edge.EdgeID()
+	e, ok := m.nodeState[eID].(*lineEdge)
+	if !ok {
+		e = &lineEdge{E: c.Edge, tolerance: &m.edgeTolerance}
+		m.nodeState[eID] = e
+	}
+	e.From, e.To = m.l.Pad(c.Edge.From()), m.l.Pad(c.Edge.To())
+	min, max := e.HitBounds()
+	area.Add(min, max, e)
+}
+
+// updateNodeHits updates the hit tester after a node has moved. Pads on
+// linked nodes are updated too, as they may move to follow the node, as
+// are the edges attached to any of those pads.
+func (m *Model) updateNodeHits(n flow.Node) {
+	started := time.Now()
+	for _, n := range append(flow.Neighbours(n), n) {
+		m.updateHit(n.NodeID())
+		for _, p := range n.Pads() {
+			m.updateHit(p.PadID())
+			for _, e := range append(p.StartEdges(), p.EndEdges()...) {
+				m.updateHit(e.EdgeID())
+			}
+		}
+	}
+	m.mkHitTime.Time(started)
+}
+
+// setZoom scales the tolerance for hitting edges, so edges remain equally
+// easy to hit at all zoom levels.
+func (m *Model) setZoom(zoom float64) {
+	m.edgeTolerance = edgeHitWidth / zoom
+	if m.h == nil {
+		return
+	}
+	for id, mn := range m.nodeState {
+		if _, isEdge := mn.(*lineEdge); isEdge {
+			m.updateHit(id)
+		}
+	}
+}
+
+// pruneNodeState forgets the state of nodes, pads and edges which are no
+// longer in the display list, so they are not added back to the hit tester.
+// The removed state is returned.
+func (m *Model) pruneNodeState() []modelNode {
+	live := make(map[string]struct{}, len(m.displayList))
+	for _, cmd := range m.displayList {
+		switch c := cmd.(type) {
+		case flow.DrawNodeCmd:
+			live[c.Node.NodeID()] = struct{}{}
+		case flow.DrawPadCmd:
+			live[c.Pad.PadID()] = struct{}{}
+		case flow.DrawEdgeCmd:
+			live[c.Edge.EdgeID()] = struct{}{}
+		}
+	}
+	var removed []modelNode
+	for id, mn := range m.nodeState {
+		if _, ok := live[id]; !ok {
+			removed = append(removed, mn)
+			delete(m.nodeState, id)
+		}
+	}
+	return removed
+}
+
+func (m *Model) updateHit(id string) {
+	if mn, ok := m.nodeState[id]; ok {
+		min, max := mn.HitBounds()
+		m.h.Update(mn, min, max)
+	}
+}
+
 func (m *Model) buildModel() {
 	started := time.Now()
+	m.pruneNodeState()
 	m.h = hit.NewArea(m.nMin, m.nMax)
+	// Edges are inserted first, so nodes and pads are hit in preference
+	// to edges passing underneath them.
+	for _, cmd := range m.displayList {
+		if c, ok := cmd.(flow.DrawEdgeCmd); ok {
+			m.insertEdgeObj(c, m.h)
+		}
+	}
 	for _, cmd := range m.displayList {
 		switch c := cmd.(type) {
 		case flow.DrawNodeCmd:
 			m.insertNodeObj(c, m.h)
 		case flow.DrawPadCmd:
 			m.insertPadObj(c, m.h)
-		case flow.DrawEdgeCmd:
-			m.nodeState[c.Edge.EdgeID()] = &lineEdge{
-				E:    c.Edge,
-				From: m.l.Pad(c.Edge.From()),
-				To:   m.l.Pad(c.Edge.To()),
-			}
 		}
 	}
 
@@ -162,12 +228,17 @@ func (m *Model) SetTargetActive(target hit.TestableObj, a bool) {
 		t.active = a
 	case *circPad:
 		t.active = a
+	case *lineEdge:
+		t.active = a
 	default:
 		panic("type not handled")
 	}
 }
 
-var ErrNodeNotLinkable = errors.New("node cannot be linked by user")
+var (
+	ErrNodeNotLinkable = errors.New("node cannot be linked by user")
+	ErrLinkSameNode    = errors.New("cannot link a node to itself")
+)
 
 // UserLinkable describes a node which can have pads linked to another by
 // the user performing a drag from one node to another.
@@ -176,24 +247,180 @@ type UserLinkable interface {
 	LinkPads(toNode flow.Node, fromPad, toPad flow.Pad) (flow.Edge, error)
 }
 
+// LinkChecker describes a node which can refuse links to specific pads
+// before they are made, so the user can see which pads are compatible while
+// dragging a link.
+type LinkChecker interface {
+	flow.Node
+	// CanLink returns a non-nil error describing why a link from fromPad,
+	// which is on this node, to toPad cannot be made.
+	CanLink(fromPad, toPad flow.Pad) error
+}
+
+// checkLink classifies toPad as a target for a link from fromPad, returning
+// the reason if the link cannot be made. An existing edge which is being
+// reconnected can be passed as ignore, so the pads it connects are not
+// considered already linked.
+func (m *Model) checkLink(fromPad, toPad flow.Pad, ignore flow.Edge) (render.LinkState, error) {
+	fromNode := fromPad.Parent()
+	if fromNode == toPad.Parent() {
+		return render.LinkIncompatible, ErrLinkSameNode
+	}
+	for _, e := range flow.EdgesBetween(fromPad, toPad) {
+		if e != ignore {
+			return render.LinkConnected, flow.ErrAlreadyLinked
+		}
+	}
+	if _, ok := fromNode.(UserLinkable); !ok {
+		return render.LinkIncompatible, ErrNodeNotLinkable
+	}
+	if lc, ok := fromNode.(LinkChecker); ok {
+		if err := lc.CanLink(fromPad, toPad); err != nil {
+			return render.LinkIncompatible, err
+		}
+	}
+	return render.LinkCompatible, nil
+}
+
+// classifyPads sets the link state of every pad as a target for a link
+// being dragged from start. If reverse is true, the link is made from the
+// target pad to start, as when the from end of an edge is being moved.
+func (m *Model) classifyPads(start *circPad, reverse bool, ignore flow.Edge) {
+	for _, mn := range m.nodeState {
+		pad, isPad := mn.(*circPad)
+		if !isPad {
+			continue
+		}
+		if pad == start {
+			pad.link = render.LinkNone
+			continue
+		}
+		if reverse {
+			pad.link, _ = m.checkLink(pad.P, start.P, ignore)
+		} else {
+			pad.link, _ = m.checkLink(start.P, pad.P, ignore)
+		}
+	}
+}
+
+// clearPadClasses resets the link state of every pad, once a link is no
+// longer being dragged.
+func (m *Model) clearPadClasses() {
+	for _, mn := range m.nodeState {
+		if pad, isPad := mn.(*circPad); isPad {
+			pad.link = render.LinkNone
+		}
+	}
+}
+
 func (m *Model) OnUserLinksPads(startPad, endPad *circPad) error {
-	fromNode, toNode := startPad.P.Parent(), endPad.P.Parent()
+	if _, err := m.checkLink(startPad.P, endPad.P, nil); err != nil {
+		return err
+	}
+	if _, err := m.linkPads(startPad.P, endPad.P); err != nil {
+		return err
+	}
+	// At this stage the two pads have had an edge allocated and been
+	// successfully connected. Lastly, we rebuild the display list to account
+	// for the new edge and any decendant links.
+	if err := m.buildDrawList(); err != nil {
+		return err
+	}
+	m.buildModel()
+	return nil
+}
+
+// linkPads links two pads through the UserLinkable implementation of the
+// node which owns fromPad.
+func (m *Model) linkPads(fromPad, toPad flow.Pad) (flow.Edge, error) {
+	fromNode, toNode := fromPad.Parent(), toPad.Parent()
+
+	linkableBaseNode, ok := fromNode.(UserLinkable)
+	if !ok {
+		return nil, ErrNodeNotLinkable
+	}
+	e, err := linkableBaseNode.LinkPads(toNode, fromPad, toPad)
+	if err != nil {
+		return nil, err
+	}
+	// Pads which are automatically positioned may need to move to face
+	// their new neighbour. This also covers pads on the other node.
+	m.l.RecomputePadPositions(fromNode)
+	return e, nil
+}
+
+// ReconnectEdge replaces an edge with a new edge between the given pads.
+// The old edge is detached from its pads while the new edge is linked, so
+// linking sees the same edges checkLink does when ignoring the old edge. If
+// linking fails, the old edge is attached to its pads again.
+func (m *Model) ReconnectEdge(old flow.Edge, fromPad, toPad flow.Pad) (flow.Edge, error) {
+	oldFrom, oldTo := old.From(), old.To()
+	if _, err := m.checkLink(fromPad, toPad, old); err != nil {
+		return nil, err
+	}
+	for _, p := range []flow.Pad{oldFrom, oldTo} {
+		if p != nil {
+			p.Disconnect(old)
+		}
+	}
+	e, err := m.linkPads(fromPad, toPad)
+	if err != nil {
+		if rerr := attachEdge(old, oldFrom, oldTo); rerr != nil {
+			return nil, rerr
+		}
+		return nil, err
+	}
+
+	old.Disconnect()
+	for _, p := range []flow.Pad{oldFrom, oldTo} {
+		if p != nil {
+			m.l.RecomputePadPositions(p.Parent())
+		}
+	}
+	if err := m.buildDrawList(); err != nil {
+		return e, err
+	}
+	m.buildModel()
+	return e, nil
+}
 
-	if linkableBaseNode, ok := fromNode.(UserLinkable); ok {
-		if _, err := linkableBaseNode.LinkPads(toNode, startPad.P, endPad.P); err != nil {
+// attachEdge connects an edge which was detached from its pads back to them.
+func attachEdge(e flow.Edge, from, to flow.Pad) error {
+	if from != nil {
+		if err := from.ConnectTo(e); err != nil {
 			return err
 		}
-		// At this stage the two pads have had an edge allocated and been
-		// successfully connected. Lastly, we rebuild the display list to account
-		// for the new edge and any decendant links.
-		if err := m.buildDrawList(); err != nil {
+	}
+	if to != nil {
+		if err := to.ConnectFrom(e); err != nil {
 			return err
 		}
-		m.buildModel()
-		return nil
 	}
+	return nil
+}
 
-	return ErrNodeNotLinkable
+// NearestLinkablePad returns the pad closest to the given point which could
+// be linked to from startPad, or nil if there is no such pad within maxDist.
+func (m *Model) NearestLinkablePad(p hit.Point, startPad *circPad, maxDist float64) *circPad {
+	return m.NearestPad(p, maxDist, func(pad *circPad) bool {
+		return pad != startPad && pad.P.Parent() != startPad.P.Parent()
+	})
+}
+
+// NearestPad returns the pad closest to the given point for which filter
+// returns true, or nil if there is no such pad within maxDist.
+func (m *Model) NearestPad(p hit.Point, maxDist float64, filter func(*circPad) bool) *circPad {
+	start := time.Now()
+	defer m.hitTime.Time(start)
+
+	near := m.h.Nearest(p, 1, maxDist, func(obj hit.TestableObj) bool {
+		pad, isPad := obj.(*circPad)
+		return isPad && filter(pad)
+	})
+	if len(near) == 0 {
+		return nil
+	}
+	return near[0].Obj.(*circPad)
 }
 
 func (m *Model) HitTest(p hit.Point) hit.TestableObj {
@@ -202,3 +429,48 @@ func (m *Model) HitTest(p hit.Point) hit.TestableObj {
 	m.hitTime.Time(start)
 	return tp
 }
+
+// HitTestBelow returns the object under the point which is next below
+// current, wrapping around to the topmost object. If current is not under
+// the point, the topmost object is returned.
+func (m *Model) HitTestBelow(p hit.Point, current hit.TestableObj) hit.TestableObj {
+	start := time.Now()
+	hits := m.h.TestAll(p)
+	m.hitTime.Time(start)
+
+	if len(hits) == 0 {
+		return nil
+	}
+	for i, h := range hits {
+		if h == current {
+			return hits[(i+1)%len(hits)]
+		}
+	}
+	return hits[0]
+}
+
+// QueryNodes returns the nodes which overlap the given rectangle, topmost
+// first.
+func (m *Model) QueryNodes(min, max hit.Point) []hit.TestableObj {
+	start := time.Now()
+	defer m.hitTime.Time(start)
+
+	var out []hit.TestableObj
+	for _, obj := range m.h.QueryRect(min, max, hit.Overlapping) {
+		if _, isNode := obj.(*rectNode); isNode {
+			out = append(out, obj)
+		}
+	}
+	return out
+}
+
+// nodeTargets returns the hit targets of all nodes, in draw order.
+func (m *Model) nodeTargets() []hit.TestableObj {
+	var out []hit.TestableObj
+	for _, cmd := range m.displayList {
+		if c, ok := cmd.(flow.DrawNodeCmd); ok {
+			out = append(out, m.nodeState[c.Node.NodeID()])
+		}
+	}
+	return out
+}
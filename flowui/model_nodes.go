@@ -4,6 +4,7 @@ import (
 	"math"
 
 	"github.com/twitchyliquid64/diagg/flow"
+	"github.com/twitchyliquid64/diagg/flowui/render"
 	"github.com/twitchyliquid64/diagg/hit"
 )
 
@@ -13,6 +14,17 @@ type modelNode interface {
 	Pos() (float64, float64)
 	Active() bool
 	HitTest(hit.Point) bool
+	// HitBounds returns the bounding box of the element.
+	HitBounds() (min, max hit.Point)
+}
+
+// ShapedNode describes a node which is not rectangular, and should be hit
+// tested against its shape rather than its bounding box.
+type ShapedNode interface {
+	flow.Node
+	// NodeShape returns the shape of the node, when centered on the given
+	// position.
+	NodeShape(x, y float64) hit.Shape
 }
 
 // rectNode represents flowchart, layout, and UI state information
@@ -29,9 +41,23 @@ func (n rectNode) Node() flow.Node { return n.N }
 
 func (n rectNode) Active() bool { return n.active }
 
+func (n rectNode) HitBounds() (hit.Point, hit.Point) {
+	x, y := n.Pos()
+	if sn, ok := n.N.(ShapedNode); ok {
+		return sn.NodeShape(x, y).Bounds()
+	}
+	w, h := n.N.Size()
+	return hit.Point{X: x - w/2, Y: y - h/2}, hit.Point{X: x + w/2, Y: y + h/2}
+}
+
 // HitTest returns true as rectangles should be completely represented
-// by their min/max points tracked by the hit tester.
-func (rectNode) HitTest(p hit.Point) bool {
+// by their min/max points tracked by the hit tester. Nodes with a
+// custom shape are tested against it.
+func (n rectNode) HitTest(p hit.Point) bool {
+	if sn, ok := n.N.(ShapedNode); ok {
+		x, y := n.Pos()
+		return sn.NodeShape(x, y).HitTest(p)
+	}
 	return true
 }
 
@@ -41,14 +67,24 @@ type circPad struct {
 	P      flow.Pad
 	Layout *flow.PadLayout
 	active bool
+	// link classifies the pad while the user is dragging a link.
+	link render.LinkState
 }
 
 func (p circPad) Pos() (float64, float64) { return p.Layout.Pos() }
 
+func (p circPad) LinkState() render.LinkState { return p.link }
+
 func (p circPad) Pad() flow.Pad { return p.P }
 
 func (p circPad) Active() bool { return p.active }
 
+func (p circPad) HitBounds() (hit.Point, hit.Point) {
+	x, y := p.Pos()
+	dia, _ := p.P.Size()
+	return hit.Point{X: x - dia/2, Y: y - dia/2}, hit.Point{X: x + dia/2, Y: y + dia/2}
+}
+
 // HitTest returns true if the point is within the circle.
 func (p circPad) HitTest(tp hit.Point) bool {
 	centerX, centerY := p.Pos()
@@ -57,11 +93,23 @@ func (p circPad) HitTest(tp hit.Point) bool {
 	return distSq < math.Pow(dia/2, 2)
 }
 
+// Distance returns the distance from the point to the edge of the circle.
+func (p circPad) Distance(tp hit.Point) float64 {
+	centerX, centerY := p.Pos()
+	dia, _ := p.Pad().Size()
+	return math.Max(0, math.Hypot(tp.X-centerX, tp.Y-centerY)-dia/2)
+}
+
 // lineEdge represents flowchart, layout, and UI state information
 // for an edge in the flowchart.
 type lineEdge struct {
 	E        flow.Edge
 	From, To *flow.PadLayout
+	// tolerance is the distance from the line which is considered a hit,
+	// which is shared by all edges so it can be scaled with the zoom level.
+	tolerance *float64
+	active    bool
+	hover     bool
 }
 
 func (e lineEdge) FromPos() (float64, float64) { return e.From.Pos() }
@@ -75,6 +123,54 @@ func (e lineEdge) Pos() (float64, float64) {
 
 func (e lineEdge) Edge() flow.Edge { return e.E }
 
-func (e lineEdge) Active() bool { return false }
+func (e lineEdge) Active() bool { return e.active }
 
-func (e lineEdge) HitTest(tp hit.Point) bool { return false }
+func (e lineEdge) Hovered() bool { return e.hover }
+
+// points returns the points the edge passes through, including any
+// waypoints if the edge is routed. For curved edges, the waypoints include
+// the control points, which enclose the curve.
+func (e lineEdge) points() []hit.Point {
+	sx, sy := e.FromPos()
+	ex, ey := e.ToPos()
+	pts := []hit.Point{{X: sx, Y: sy}}
+	if re, ok := e.E.(flow.RoutedEdge); ok {
+		for _, wp := range re.Waypoints() {
+			pts = append(pts, hit.Point{X: wp[0], Y: wp[1]})
+		}
+	}
+	return append(pts, hit.Point{X: ex, Y: ey})
+}
+
+func (e lineEdge) tol() float64 {
+	if e.tolerance == nil {
+		return 0
+	}
+	return *e.tolerance
+}
+
+func (e lineEdge) HitBounds() (hit.Point, hit.Point) {
+	pts := e.points()
+	min, max := pts[0], pts[0]
+	for _, p := range pts[1:] {
+		min.X, min.Y = math.Min(min.X, p.X), math.Min(min.Y, p.Y)
+		max.X, max.Y = math.Max(max.X, p.X), math.Max(max.Y, p.Y)
+	}
+	t := e.tol()
+	return hit.Point{X: min.X - t, Y: min.Y - t}, hit.Point{X: max.X + t, Y: max.Y + t}
+}
+
+// HitTest returns true if the point is within the tolerance of the line,
+// or of the curves if the edge is curved.
+func (e lineEdge) HitTest(tp hit.Point) bool {
+	pts := e.points()
+	if _, curved := flow.CurveWaypoints(e.E); curved {
+		for i := 0; i+3 < len(pts); i += 3 {
+			if hit.CubicDist(tp, pts[i], pts[i+1], pts[i+2], pts[i+3]) <= e.tol() {
+				return true
+			}
+		}
+		return false
+	}
+	return hit.PolylineDist(tp, pts) <= e.tol()
+}
@@ -0,0 +1,226 @@
+package overlays
+
+import (
+	"math"
+	"time"
+
+	"github.com/gotk3/gotk3/cairo"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/twitchyliquid64/diagg/flowui"
+)
+
+const (
+	minimapMargin  = 3
+	minimapPadding = 6
+	// minimapRefresh is the shortest time between renders of the minimap,
+	// so that dragging nodes does not re-render it on every frame.
+	minimapRefresh = 100 * time.Millisecond
+)
+
+// Minimap implements an overlay which shows a scaled-down view of the whole
+// flowchart in the bottom-left corner, along with the area currently in
+// view. Clicking or dragging within the minimap pans the flowchart.
+type Minimap struct {
+	fcv           *flowui.FlowchartView
+	width, height float64
+
+	// Size of the drawing area, and the bounds of the minimap within it.
+	w, h                int
+	leftBound, topBound float64
+
+	// The flowchart is drawn to surface, which is only redrawn when the
+	// generation of the layout changes, at most once every minimapRefresh.
+	// timer is set while a render is waiting for that interval to pass.
+	surface  *cairo.Surface
+	gen      uint64
+	rendered time.Time
+	timer    glib.SourceHandle
+	// scale and the origin map flowchart coordinates to the minimap.
+	scale            float64
+	originX, originY float64
+
+	dragging bool
+}
+
+// NewMinimap constructs a minimap overlay of the given size, showing the
+// flowchart displayed by fcv.
+func NewMinimap(fcv *flowui.FlowchartView, width, height int) *Minimap {
+	return &Minimap{
+		fcv:    fcv,
+		width:  float64(width),
+		height: float64(height),
+	}
+}
+
+// Configure implements flowui.Overlay.
+func (m *Minimap) Configure(w, h int) {
+	m.w, m.h = w, h
+	m.leftBound = minimapMargin
+	m.topBound = float64(h) - m.height - minimapMargin
+}
+
+func (m *Minimap) contains(x, y float64) bool {
+	return x >= m.leftBound && x <= m.leftBound+m.width && y >= m.topBound && y <= m.topBound+m.height
+}
+
+// HandleMotionEvent implements flowui.Overlay.
+func (m *Minimap) HandleMotionEvent(evt *gdk.EventMotion) bool {
+	if !m.dragging {
+		return false
+	}
+	m.panTo(evt.MotionVal())
+	return true
+}
+
+// HandlePressEvent implements flowui.Overlay.
+func (m *Minimap) HandlePressEvent(event *gdk.Event, press bool) bool {
+	evt := gdk.EventButtonNewFromEvent(event)
+	x, y := evt.MotionVal()
+	if !press {
+		wasDragging := m.dragging
+		m.dragging = false
+		return wasDragging
+	}
+
+	if evt.Button() != 1 || !m.contains(x, y) {
+		return false
+	}
+	m.dragging = true
+	m.panTo(x, y)
+	return true
+}
+
+// HandleScrollEvent implements flowui.Overlay.
+func (m *Minimap) HandleScrollEvent(evt *gdk.EventScroll) bool {
+	return false
+}
+
+// panTo centers the view on the flowchart position shown at the given
+// point of the drawing area.
+func (m *Minimap) panTo(x, y float64) {
+	if m.scale == 0 {
+		return
+	}
+	fx := (x - m.leftBound - m.originX) / m.scale
+	fy := (y - m.topBound - m.originY) / m.scale
+
+	_, _, zoom := m.fcv.GetViewParameters()
+	m.fcv.SetViewParameters(float64(m.w)/2-fx*zoom, float64(m.h)/2-fy*zoom, zoom)
+}
+
+// render draws the flowchart to the cached surface.
+func (m *Minimap) render() {
+	var (
+		l        = m.fcv.Layout()
+		snap     = l.Snapshot()
+		min, max = snap.Bounds()
+		w, h     = max[0] - min[0], max[1] - min[1]
+		availW   = m.width - 2*minimapPadding
+		availH   = m.height - 2*minimapPadding
+	)
+	m.gen = l.Generation()
+	m.rendered = time.Now()
+	if w <= 0 || h <= 0 {
+		m.scale = 0
+	} else {
+		m.scale = math.Min(availW/w, availH/h)
+	}
+	m.originX = minimapPadding + (availW-w*m.scale)/2 - min[0]*m.scale
+	m.originY = minimapPadding + (availH-h*m.scale)/2 - min[1]*m.scale
+
+	if m.surface == nil {
+		m.surface = cairo.CreateImageSurface(cairo.FORMAT_ARGB32, int(m.width), int(m.height))
+	}
+	cr := cairo.Create(m.surface)
+	cr.SetOperator(cairo.OPERATOR_SOURCE)
+	cr.SetSourceRGBA(0.12, 0.12, 0.12, 0.85)
+	cr.Paint()
+	cr.SetOperator(cairo.OPERATOR_OVER)
+	if m.scale == 0 {
+		return
+	}
+	cr.Translate(m.originX, m.originY)
+	cr.Scale(m.scale, m.scale)
+
+	cr.SetSourceRGB(0.6, 0.6, 0.6)
+	cr.SetLineWidth(1 / m.scale)
+	for _, np := range snap.Dump() {
+		for _, p := range np.Node.Pads() {
+			for _, e := range p.StartEdges() {
+				if e.To() == nil {
+					continue
+				}
+				fx, fy := snap.Pad(e.From()).Pos()
+				tx, ty := snap.Pad(e.To()).Pos()
+				cr.MoveTo(fx, fy)
+				cr.LineTo(tx, ty)
+			}
+		}
+	}
+	cr.Stroke()
+
+	cr.SetSourceRGB(0.5, 0.1, 0.1)
+	for _, np := range snap.Dump() {
+		w, h := np.Node.Size()
+		cr.Rectangle(np.Pos.X-w/2, np.Pos.Y-h/2, w, h)
+	}
+	cr.Fill()
+	m.surface.Flush()
+}
+
+// refresh re-renders the stale minimap if it was not rendered recently.
+// Otherwise, the flowchart is redrawn once the interval has passed, so
+// changes which stop within it are still shown.
+func (m *Minimap) refresh(da *gtk.DrawingArea) {
+	wait := minimapRefresh - time.Since(m.rendered)
+	if wait <= 0 {
+		m.render()
+		return
+	}
+	if m.timer != 0 {
+		return
+	}
+	m.timer, _ = glib.TimeoutAdd(uint(wait/time.Millisecond)+1, func() bool {
+		m.timer = 0
+		da.QueueDraw()
+		return false
+	})
+}
+
+// Draw implements flowui.Overlay.
+func (m *Minimap) Draw(da *gtk.DrawingArea, cr *cairo.Context) {
+	if m.surface == nil {
+		m.render()
+	} else if m.gen != m.fcv.Layout().Generation() {
+		m.refresh(da)
+	}
+
+	cr.SetSourceSurface(m.surface, m.leftBound, m.topBound)
+	cr.Paint()
+
+	cr.SetLineWidth(lineThickness)
+	cr.SetSourceRGB(0.3, 0.3, 0.3)
+	cr.Rectangle(m.leftBound, m.topBound, m.width, m.height)
+	cr.Stroke()
+
+	if m.scale == 0 {
+		return
+	}
+
+	// Draw the area of the flowchart currently in view, clipped to the
+	// bounds of the minimap.
+	offX, offY, zoom := m.fcv.GetViewParameters()
+	minX, minY := -offX/zoom, -offY/zoom
+	maxX, maxY := (float64(m.w)-offX)/zoom, (float64(m.h)-offY)/zoom
+
+	cr.Save()
+	cr.Rectangle(m.leftBound, m.topBound, m.width, m.height)
+	cr.Clip()
+	cr.SetSourceRGB(1, 1, 1)
+	cr.Rectangle(m.leftBound+m.originX+minX*m.scale, m.topBound+m.originY+minY*m.scale,
+		(maxX-minX)*m.scale, (maxY-minY)*m.scale)
+	cr.Stroke()
+	cr.Restore()
+}
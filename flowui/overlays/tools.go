@@ -208,8 +208,8 @@ func (o *ToolOverlay) HandleScrollEvent(evt *gdk.EventScroll) bool {
 	return false
 }
 
-// HandleKeypress implements tab & numbering shortcuts for keypress events. The
-// caller should invoke this function for key presses.
+// HandleKeypress implements tab & numbering shortcuts for keypress events. It
+// is invoked by the FlowchartView for key presses while it has focus.
 func (o *ToolOverlay) HandleKeypress(keyEvent *gdk.EventKey) bool {
 	if o.showSelection {
 		kv := keyEvent.KeyVal()
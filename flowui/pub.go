@@ -2,8 +2,6 @@
 package flowui
 
 import (
-	"fmt"
-
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
@@ -13,13 +11,20 @@ import (
 
 var flowSelectionSig, _ = glib.SignalNew("flow-selection")
 var createdLinkSig, _ = glib.SignalNew("flow-created-link")
+var headlineEditedSig, _ = glib.SignalNew("flow-headline-edited")
 
 // NewFlowchartView constructs a new flowchart display widget, reading nodes
 // and position information from the provided layout.
 func NewFlowchartView(l *flow.Layout) (*FlowchartView, *gtk.DrawingArea, error) {
 	var err error
 	fcv := &FlowchartView{
-		zoom: 1,
+		zoom:    1,
+		minZoom: defaultMinZoom,
+		maxZoom: defaultMaxZoom,
+		keymap:  DefaultKeymap(),
+		grid:    DefaultGridSettings(),
+
+		tooltipDelay: defaultTooltipDelay,
 		model: Model{
 			l:         l,
 			r:         &render.BasicRenderer{},
@@ -45,12 +50,18 @@ func NewFlowchartView(l *flow.Layout) (*FlowchartView, *gtk.DrawingArea, error)
 	fcv.da.Connect("button-release-event", fcv.onReleaseEvent)
 	fcv.da.Connect("scroll-event", fcv.onScrollEvent)
 	fcv.da.Connect("leave-notify-event", fcv.onLeftFocus)
+	fcv.da.Connect("key-press-event", fcv.onKeyPressEvent)
+	fcv.da.Connect("key-release-event", fcv.onKeyReleaseEvent)
+	fcv.da.SetCanFocus(true)
 	fcv.da.SetEvents(int(gdk.POINTER_MOTION_MASK |
 		gdk.BUTTON_PRESS_MASK |
 		gdk.BUTTON_RELEASE_MASK |
 		gdk.SCROLL_MASK |
+		gdk.KEY_PRESS_MASK |
+		gdk.KEY_RELEASE_MASK |
 		gdk.LEAVE_NOTIFY_MASK)) // GDK_MOTION_NOTIFY
 
+	fcv.model.setZoom(fcv.zoom)
 	err = fcv.model.initRenderState()
 
 	// Set initial offsets so the left-top side is in full view.
@@ -61,7 +72,10 @@ func NewFlowchartView(l *flow.Layout) (*FlowchartView, *gtk.DrawingArea, error)
 // AddNode inserts a new node into the layout and view.
 func (fcv *FlowchartView) AddNode(n flow.Node, x, y float64) error {
 	pos := fcv.drawCoordsToFlow(x, y)
-	x, y = quantizeCoords(pos.X, pos.Y)
+	x, y = pos.X, pos.Y
+	if fcv.grid.Snap {
+		x, y = fcv.grid.snapToGrid(x, y)
+	}
 	fcv.model.l.MoveNode(n, x, y)
 
 	if err := fcv.model.buildDrawList(); err != nil {
@@ -74,71 +88,192 @@ func (fcv *FlowchartView) AddNode(n flow.Node, x, y float64) error {
 
 // DeleteNode removes a node from the flowchart, breaking all links.
 func (fcv *FlowchartView) DeleteNode(n flow.Node) error {
+	if fcv.editor != nil && fcv.editor.node == n {
+		fcv.cancelHeadlineEdit()
+	}
 	if mn, ok := fcv.model.nodeState[n.NodeID()]; ok {
 		fcv.model.h.Delete(mn)
+		fcv.deselect(mn)
+		fcv.forgetHover(mn)
 		delete(fcv.model.nodeState, n.NodeID())
 	}
 	for _, p := range n.Pads() {
 		if mn, ok := fcv.model.nodeState[p.PadID()]; ok {
 			fcv.model.h.Delete(mn)
+			fcv.deselect(mn)
+			fcv.forgetHover(mn)
 		}
 		delete(fcv.model.nodeState, p.PadID())
+		for _, e := range append(p.StartEdges(), p.EndEdges()...) {
+			fcv.forgetEdge(e)
+		}
 	}
 
 	fcv.model.l.DeleteNode(n)
 	return fcv.Rebuild()
 }
 
+// DeleteEdge disconnects an edge, removing it from the flowchart.
+func (fcv *FlowchartView) DeleteEdge(e flow.Edge) error {
+	fcv.disconnectEdge(e)
+	return fcv.Rebuild()
+}
+
+// disconnectEdge disconnects an edge, updating the positions of any pads
+// which may now face elsewhere.
+func (fcv *FlowchartView) disconnectEdge(e flow.Edge) {
+	fcv.forgetEdge(e)
+	from, to := e.From(), e.To()
+	e.Disconnect()
+	for _, p := range []flow.Pad{from, to} {
+		if p != nil {
+			fcv.model.l.RecomputePadPositions(p.Parent())
+		}
+	}
+}
+
+// ReconnectEdge replaces an edge with a new edge between the given pads,
+// linking them through the UserLinkable implementation of the node which
+// owns from. The old edge is only disconnected if linking succeeds. The
+// reconnect callback is invoked on success.
+func (fcv *FlowchartView) ReconnectEdge(e flow.Edge, from, to flow.Pad) (flow.Edge, error) {
+	change := EdgeReconnect{
+		OldEdge: e,
+		OldFrom: e.From(),
+		OldTo:   e.To(),
+		NewFrom: from,
+		NewTo:   to,
+	}
+	newEdge, err := fcv.model.ReconnectEdge(e, from, to)
+	if newEdge == nil {
+		return nil, err
+	}
+	fcv.forgetEdge(e)
+	fcv.da.QueueDraw()
+	if err != nil {
+		return newEdge, err
+	}
+
+	change.NewEdge = newEdge
+	if fcv.reconnectCB != nil {
+		fcv.reconnectCB(change)
+	}
+	return newEdge, nil
+}
+
+// UndoReconnect reverses a change reported to the reconnect callback, moving
+// the new edge back to the pads the old edge was connected to. As this
+// creates another edge, the restored edge is returned. The reconnect
+// callback is not invoked, so undoing a change does not record a new one.
+func (fcv *FlowchartView) UndoReconnect(r EdgeReconnect) (flow.Edge, error) {
+	restored, err := fcv.model.ReconnectEdge(r.NewEdge, r.OldFrom, r.OldTo)
+	if restored == nil {
+		return nil, err
+	}
+	fcv.forgetEdge(r.NewEdge)
+	fcv.da.QueueDraw()
+	return restored, err
+}
+
+// forgetEdge discards the view state of an edge which is being removed.
+func (fcv *FlowchartView) forgetEdge(e flow.Edge) {
+	if mn, ok := fcv.model.nodeState[e.EdgeID()]; ok {
+		fcv.model.h.Delete(mn)
+		fcv.forget(mn)
+		delete(fcv.model.nodeState, e.EdgeID())
+	}
+}
+
+// forget clears any selection or hover state held for an element.
+func (fcv *FlowchartView) forget(mn modelNode) {
+	fcv.deselect(mn)
+	if fcv.hoverEdge == mn {
+		fcv.hoverEdge = nil
+	}
+	fcv.forgetHover(mn)
+}
+
 // Rebuild discards all internal state, rebuilding the view internals from
-// the layout.
+// the layout. Elements which were removed from the flowchart without going
+// through the view are deselected.
 func (fcv *FlowchartView) Rebuild() error {
 	if err := fcv.model.buildDrawList(); err != nil {
 		return err
 	}
+	for _, mn := range fcv.model.pruneNodeState() {
+		fcv.forget(mn)
+	}
 	fcv.model.buildModel()
 	fcv.da.QueueDraw()
 	return nil
 }
 
+// ClearSelection deselects all elements.
 func (fcv *FlowchartView) ClearSelection() {
-	fcv.model.SetTargetActive(fcv.lmc.target, false)
+	fcv.setSelection(nil)
+	fcv.lmc.target = nil
 	fcv.da.QueueDraw()
 }
 
-// GetSelection returns the currently selected node or pad.
+// GetSelection returns the most recently clicked node, pad or edge.
 func (fcv *FlowchartView) GetSelection() interface{} {
-	switch t := fcv.lmc.target.(type) {
-	case *rectNode:
-		return t.Node()
-	case *circPad:
-		return t.Pad()
-	case nil:
-		return nil
-	default:
-		panic(fmt.Sprintf("cannot handle type: %T", t))
-	}
+	return flowObject(fcv.lmc.target)
 }
 
-// GetAtPosition returns the pad or node at the given position, or nil if
-// the provided position was empty space.
-func (fcv *FlowchartView) GetAtPosition(x, y float64) interface{} {
-	tp := fcv.drawCoordsToFlow(x, y)
+// GetSelectionSet returns all selected nodes, pads and edges, in the order
+// they were selected.
+func (fcv *FlowchartView) GetSelectionSet() []interface{} {
+	out := make([]interface{}, len(fcv.selection))
+	for i, s := range fcv.selection {
+		out[i] = flowObject(s)
+	}
+	return out
+}
 
-	if t := fcv.model.HitTest(tp); t != nil {
-		switch m := t.(type) {
+// DeleteSelection removes all selected nodes and edges from the flowchart.
+// Links to deleted nodes are broken.
+func (fcv *FlowchartView) DeleteSelection() error {
+	var (
+		nodes []flow.Node
+		edges []flow.Edge
+	)
+	for _, s := range fcv.selection {
+		switch t := s.(type) {
 		case *rectNode:
-			return m.Node()
-		case *circPad:
-			return m.Pad()
-		case nil:
-			return nil
-		default:
-			panic(fmt.Sprintf("cannot handle type: %T", m))
+			nodes = append(nodes, t.Node())
+		case *lineEdge:
+			edges = append(edges, t.Edge())
 		}
 	}
+
+	for _, e := range edges {
+		fcv.disconnectEdge(e)
+	}
+	for _, n := range nodes {
+		if err := fcv.DeleteNode(n); err != nil {
+			return err
+		}
+	}
+	if len(nodes) == 0 {
+		if err := fcv.Rebuild(); err != nil {
+			return err
+		}
+	}
+	fcv.selectionChanged()
 	return nil
 }
 
+// GetAtPosition returns the pad, node or edge at the given position, or nil if
+// the provided position was empty space.
+func (fcv *FlowchartView) GetAtPosition(x, y float64) interface{} {
+	return flowObject(fcv.model.HitTest(fcv.drawCoordsToFlow(x, y)))
+}
+
+// Layout returns the layout the flowchart is displaying.
+func (fcv *FlowchartView) Layout() *flow.Layout {
+	return fcv.model.l
+}
+
 // GetViewParameters returns the X & Y offsets of the current view, as well
 // as the current zoom level.
 func (fcv *FlowchartView) GetViewParameters() (float64, float64, float64) {
@@ -148,7 +283,9 @@ func (fcv *FlowchartView) GetViewParameters() (float64, float64, float64) {
 // SetViewParameters sets the X & Y offsets of the current view, as well
 // as the current zoom level.
 func (fcv *FlowchartView) SetViewParameters(x, y, zoom float64) {
-	fcv.offsetX, fcv.offsetY, fcv.zoom = x, y, zoom
+	fcv.transition = nil
+	fcv.offsetX, fcv.offsetY, fcv.zoom = x, y, fcv.clampZoom(zoom)
+	fcv.model.setZoom(fcv.zoom)
 	fcv.da.QueueDraw()
 }
 
@@ -158,11 +295,84 @@ func (fcv *FlowchartView) AddOverlay(o Overlay) {
 }
 
 // SetDoubleClickCallback sets a callback to be invoked when a\
-// double-click happens with mouse button one.
+// double-click happens with mouse button one. Double-clicking a node which
+// implements EditableHeadline edits its headline instead.
 func (fcv *FlowchartView) SetDoubleClickCallback(cb func(interface{}, float64, float64)) {
 	fcv.doublePressCB = cb
 }
 
+// SetSelectionCallback sets a callback to be invoked with the full set of
+// selected elements whenever the selection changes.
+func (fcv *FlowchartView) SetSelectionCallback(cb func(sel []interface{})) {
+	fcv.selectionCB = cb
+}
+
+// SetKeymap replaces the key bindings used when the flowchart has focus.
+// Passing nil disables all key bindings.
+func (fcv *FlowchartView) SetKeymap(km Keymap) {
+	fcv.keymap = km
+}
+
+// SelectAll selects every node in the flowchart.
+func (fcv *FlowchartView) SelectAll() {
+	fcv.setSelection(fcv.model.nodeTargets())
+	fcv.selectionChanged()
+	fcv.da.QueueDraw()
+}
+
+// SetReconnectCallback sets a callback to be invoked when an edge is
+// reconnected to different pads. No signal is emitted for reconnected
+// edges, as signals cannot carry the edges and pads involved, so the
+// callback is the only way to be notified of them.
+func (fcv *FlowchartView) SetReconnectCallback(cb func(EdgeReconnect)) {
+	fcv.reconnectCB = cb
+}
+
+// SetContextMenuProvider sets the provider of context menus shown when the
+// user right-clicks on the flowchart. While a provider is set, the view is
+// panned by dragging with the middle mouse button, or with the left mouse
+// button while space is held, rather than the right mouse button. Passing
+// nil restores panning with the right mouse button.
+func (fcv *FlowchartView) SetContextMenuProvider(p ContextMenuProvider) {
+	fcv.menuProvider = p
+}
+
+// GridSettings returns the settings of the grid nodes are positioned on.
+func (fcv *FlowchartView) GridSettings() GridSettings {
+	return fcv.grid
+}
+
+// SetGridSettings changes the settings of the grid nodes are positioned on.
+func (fcv *FlowchartView) SetGridSettings(gs GridSettings) {
+	fcv.grid = gs
+	fcv.da.QueueDraw()
+}
+
+// SetHeadlineEditCallback sets a callback to be invoked when the user
+// changes the headline of a node.
+func (fcv *FlowchartView) SetHeadlineEditCallback(cb func(HeadlineEdit)) {
+	fcv.headlineCB = cb
+}
+
+// SetHoverCallback sets a callback to be invoked when the element under the
+// mouse changes.
+func (fcv *FlowchartView) SetHoverCallback(cb HoverCallback) {
+	fcv.hoverCB = cb
+}
+
+// SetErrorCallback sets a callback to be invoked when an action performed
+// by the user fails, such as deleting the selection from the keyboard or
+// showing a context menu.
+func (fcv *FlowchartView) SetErrorCallback(cb func(error)) {
+	fcv.errorCB = cb
+}
+
+// SetTooltipDelay sets the time in milliseconds the mouse must rest over an
+// element implementing Tooltipped before its tooltip is shown.
+func (fcv *FlowchartView) SetTooltipDelay(ms uint) {
+	fcv.tooltipDelay = ms
+}
+
 // SetRenderer changes the renderer to the provided object.
 func (fcv *FlowchartView) SetRenderer(r render.Appearance) {
 	fcv.model.r = r
@@ -0,0 +1,89 @@
+package flowui
+
+import (
+	"math"
+
+	"github.com/twitchyliquid64/diagg/flow"
+	"github.com/twitchyliquid64/diagg/hit"
+)
+
+// edgeEndGrabDist is the distance in pixels beyond the edge of a pad within
+// which an edge can be grabbed by its end, to reconnect it to another pad.
+const edgeEndGrabDist = 15
+
+// EdgeReconnect describes an edge which was moved to different pads. The
+// pads the edge was connected to beforehand are recorded, so the change can
+// be undone with FlowchartView.UndoReconnect.
+type EdgeReconnect struct {
+	OldEdge, NewEdge flow.Edge
+	OldFrom, OldTo   flow.Pad
+	NewFrom, NewTo   flow.Pad
+}
+
+// reconnectState tracks an edge being dragged by one of its ends.
+type reconnectState struct {
+	edge *lineEdge
+	// fixed is the pad at the end of the edge which is not being moved.
+	fixed *circPad
+	// movingFrom is true if the from end of the edge is being moved.
+	movingFrom bool
+}
+
+// beginReconnect starts dragging the end of the edge nearest to the point,
+// if the point is close enough to that end.
+func (fcv *FlowchartView) beginReconnect(e *lineEdge, tp hit.Point) bool {
+	var (
+		fx, fy = e.FromPos()
+		tx, ty = e.ToPos()
+		dFrom  = math.Hypot(tp.X-fx, tp.Y-fy)
+		dTo    = math.Hypot(tp.X-tx, tp.Y-ty)
+		end    = e.E.To()
+		fixed  = e.E.From()
+		dist   = dTo
+	)
+	if dFrom < dTo {
+		end, fixed, dist = e.E.From(), e.E.To(), dFrom
+	}
+	if end == nil || fixed == nil {
+		return false
+	}
+	dia, _ := end.Size()
+	if dist > dia/2+edgeEndGrabDist/fcv.zoom {
+		return false
+	}
+
+	fixedPad, ok := fcv.model.nodeState[fixed.PadID()].(*circPad)
+	if !ok {
+		return false
+	}
+	fcv.reconnect = &reconnectState{
+		edge:       e,
+		fixed:      fixedPad,
+		movingFrom: dFrom < dTo,
+	}
+	return true
+}
+
+// finishReconnect connects the dragged end of the edge to the pad it was
+// dropped on, if any.
+func (fcv *FlowchartView) finishReconnect(x, y float64) {
+	rs := fcv.reconnect
+	fcv.reconnect = nil
+
+	fcv.updateSnapTarget(rs.fixed, x, y)
+	endPad := fcv.hoverTarget
+	if endPad == nil {
+		return
+	}
+
+	from, to := rs.fixed.P, endPad.P
+	if rs.movingFrom {
+		from, to = endPad.P, rs.fixed.P
+	}
+	if from == rs.edge.E.From() && to == rs.edge.E.To() {
+		return
+	}
+	if _, err := fcv.ReconnectEdge(rs.edge.E, from, to); err != nil {
+		fcv.linkFailed(err, x, y)
+	}
+}
@@ -0,0 +1,49 @@
+// +build cgo
+
+package render
+
+import (
+	"github.com/gotk3/gotk3/cairo"
+)
+
+// CairoPainter implements Painter by drawing to a Cairo context.
+type CairoPainter struct {
+	cr *cairo.Context
+}
+
+// NewCairoPainter returns a Painter which draws to the given context.
+func NewCairoPainter(cr *cairo.Context) *CairoPainter {
+	return &CairoPainter{cr: cr}
+}
+
+func (p *CairoPainter) SetSourceRGB(r, g, b float64)     { p.cr.SetSourceRGB(r, g, b) }
+func (p *CairoPainter) SetSourceRGBA(r, g, b, a float64) { p.cr.SetSourceRGBA(r, g, b, a) }
+func (p *CairoPainter) SetLineWidth(w float64)           { p.cr.SetLineWidth(w) }
+func (p *CairoPainter) LineWidth() float64               { return p.cr.GetLineWidth() }
+func (p *CairoPainter) SetDash(dashes []float64, offset float64) {
+	p.cr.SetDash(dashes, offset)
+}
+
+func (p *CairoPainter) NewPath()            { p.cr.NewPath() }
+func (p *CairoPainter) MoveTo(x, y float64) { p.cr.MoveTo(x, y) }
+func (p *CairoPainter) LineTo(x, y float64) { p.cr.LineTo(x, y) }
+func (p *CairoPainter) ClosePath()          { p.cr.ClosePath() }
+func (p *CairoPainter) Arc(xc, yc, radius, angle1, angle2 float64) {
+	p.cr.Arc(xc, yc, radius, angle1, angle2)
+}
+func (p *CairoPainter) CurveTo(x1, y1, x2, y2, x3, y3 float64) {
+	p.cr.CurveTo(x1, y1, x2, y2, x3, y3)
+}
+
+func (p *CairoPainter) Fill()           { p.cr.Fill() }
+func (p *CairoPainter) Stroke()         { p.cr.Stroke() }
+func (p *CairoPainter) StrokePreserve() { p.cr.StrokePreserve() }
+
+// Text draws text in the default font of Cairo, which may differ from the
+// Go regular font measured by flow/gofont and used by ImagePainter.
+func (p *CairoPainter) Text(x, y, size float64, text string) {
+	p.cr.MoveTo(x, y)
+	p.cr.SetFontSize(size)
+	p.cr.ShowText(text)
+	p.cr.NewPath()
+}
@@ -0,0 +1,89 @@
+package render
+
+import (
+	"errors"
+
+	"github.com/twitchyliquid64/diagg/flow"
+)
+
+// ErrEmptyRegion is returned when exporting a region with no area.
+var ErrEmptyRegion = errors.New("export region is empty")
+
+// ExportOptions describes how a flowchart is rendered when exported.
+type ExportOptions struct {
+	// Scale is the number of output units (pixels or points) per unit of
+	// flowchart coordinates. A scale of zero is treated as 1.
+	Scale float64
+	// Min and Max describe the region of the flowchart to export, in
+	// flowchart coordinates. If they are equal, the bounds of the whole
+	// layout are used.
+	Min, Max [2]float64
+	// Padding is added around the region, in flowchart coordinates.
+	Padding float64
+	// Background is the RGBA color the output is filled with before the
+	// flowchart is drawn. The zero value leaves the background transparent.
+	Background [4]float64
+	// AnimStep is passed to the appearance as the animation time.
+	AnimStep int64
+}
+
+func (o ExportOptions) scale() float64 {
+	if o.Scale <= 0 {
+		return 1
+	}
+	return o.Scale
+}
+
+// exportNode, exportPad and exportEdge present elements of a layout to an
+// Appearance, in place of the interactive elements of a FlowchartView.
+type exportNode struct {
+	n  flow.Node
+	nl *flow.NodeLayout
+}
+
+func (n exportNode) Pos() (float64, float64) { return n.nl.Pos() }
+func (n exportNode) Node() flow.Node         { return n.n }
+
+type exportPad struct {
+	p  flow.Pad
+	pl *flow.PadLayout
+}
+
+func (p exportPad) Pos() (float64, float64) { return p.pl.Pos() }
+func (p exportPad) Pad() flow.Pad           { return p.p }
+
+type exportEdge struct {
+	e        flow.Edge
+	from, to *flow.PadLayout
+}
+
+func (e exportEdge) FromPos() (float64, float64) { return e.from.Pos() }
+func (e exportEdge) ToPos() (float64, float64)   { return e.to.Pos() }
+func (e exportEdge) Edge() flow.Edge             { return e.e }
+
+// exportRender holds the display list and output geometry of an export.
+type exportRender struct {
+	dl            []flow.DrawCommand
+	min           [2]float64
+	scale         float64
+	width, height float64
+}
+
+func prepareExport(l *flow.Layout, opts ExportOptions) (*exportRender, error) {
+	min, max, dl, err := l.DisplayList()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Min != opts.Max {
+		min, max = opts.Min, opts.Max
+	}
+	min[0], min[1] = min[0]-opts.Padding, min[1]-opts.Padding
+	max[0], max[1] = max[0]+opts.Padding, max[1]+opts.Padding
+
+	scale := opts.scale()
+	w, h := (max[0]-min[0])*scale, (max[1]-min[1])*scale
+	if w <= 0 || h <= 0 {
+		return nil, ErrEmptyRegion
+	}
+	return &exportRender{dl: dl, min: min, scale: scale, width: w, height: h}, nil
+}
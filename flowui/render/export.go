@@ -0,0 +1,120 @@
+// +build cgo
+
+package render
+
+// #cgo pkg-config: cairo
+// #include <stdlib.h>
+// #include <cairo.h>
+// #include <cairo-svg.h>
+import "C"
+
+import (
+	"math"
+	"unsafe"
+
+	"github.com/gotk3/gotk3/cairo"
+	"github.com/twitchyliquid64/diagg/flow"
+)
+
+// draw renders the display list to the context, which must be the size of
+// the output.
+func (er *exportRender) draw(cr *cairo.Context, a Appearance, opts ExportOptions) {
+	if bg := opts.Background; bg[3] > 0 {
+		cr.SetSourceRGBA(bg[0], bg[1], bg[2], bg[3])
+		cr.Paint()
+	}
+	cr.Scale(er.scale, er.scale)
+	cr.Translate(-er.min[0], -er.min[1])
+	cr.SetLineWidth(2)
+	cr.SetFillRule(cairo.FILL_RULE_EVEN_ODD)
+
+	for _, cmd := range er.dl {
+		switch c := cmd.(type) {
+		case flow.DrawNodeCmd:
+			a.DrawNode(nil, cr, opts.AnimStep, exportNode{n: c.Node, nl: c.Layout})
+		case flow.DrawPadCmd:
+			a.DrawPad(nil, cr, opts.AnimStep, exportPad{p: c.Pad, pl: c.Layout})
+		case flow.DrawEdgeCmd:
+			a.DrawEdge(nil, cr, opts.AnimStep, exportEdge{e: c.Edge, from: c.FromLayout, to: c.ToLayout})
+		}
+	}
+}
+
+// RenderImage renders the layout onto a new ARGB32 image surface.
+func RenderImage(l *flow.Layout, a Appearance, opts ExportOptions) (*cairo.Surface, error) {
+	er, err := prepareExport(l, opts)
+	if err != nil {
+		return nil, err
+	}
+	surface := cairo.CreateImageSurface(cairo.FORMAT_ARGB32, int(math.Ceil(er.width)), int(math.Ceil(er.height)))
+	if status := surface.Status(); status != cairo.STATUS_SUCCESS {
+		return nil, status.ToError()
+	}
+	er.draw(cairo.Create(surface), a, opts)
+	surface.Flush()
+	return surface, nil
+}
+
+// ExportPNG renders the layout to a PNG image at the given path.
+func ExportPNG(path string, l *flow.Layout, a Appearance, opts ExportOptions) error {
+	surface, err := RenderImage(l, a, opts)
+	if err != nil {
+		return err
+	}
+	defer surface.Close()
+	return surface.WriteToPNG(path)
+}
+
+// ExportPDF renders the layout to a single-page PDF document at the
+// given path.
+func ExportPDF(path string, l *flow.Layout, a Appearance, opts ExportOptions) error {
+	er, err := prepareExport(l, opts)
+	if err != nil {
+		return err
+	}
+	surface, err := cairo.CreatePDFSurface(path, er.width, er.height)
+	if err != nil {
+		return err
+	}
+	return finishVector(surface, er, a, opts)
+}
+
+// ExportSVG renders the layout to an SVG image at the given path.
+func ExportSVG(path string, l *flow.Layout, a Appearance, opts ExportOptions) error {
+	er, err := prepareExport(l, opts)
+	if err != nil {
+		return err
+	}
+	surface, err := createSVGSurface(path, er.width, er.height)
+	if err != nil {
+		return err
+	}
+	return finishVector(surface, er, a, opts)
+}
+
+// finishVector draws to a vector surface, then finishes the surface so the
+// output is written.
+func finishVector(surface *cairo.Surface, er *exportRender, a Appearance, opts ExportOptions) error {
+	defer surface.Close()
+	cr := cairo.Create(surface)
+	er.draw(cr, a, opts)
+	cr.ShowPage()
+	cr.Close()
+
+	C.cairo_surface_finish((*C.cairo_surface_t)(unsafe.Pointer(surface.Native())))
+	return surface.Status().ToError()
+}
+
+// createSVGSurface wraps cairo_svg_surface_create(), which gotk3 does not
+// provide.
+func createSVGSurface(path string, width, height float64) (*cairo.Surface, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	s := C.cairo_svg_surface_create(cPath, C.double(width), C.double(height))
+	if status := cairo.Status(C.cairo_surface_status(s)); status != cairo.STATUS_SUCCESS {
+		C.cairo_surface_destroy(s)
+		return nil, status.ToError()
+	}
+	return cairo.NewSurface(uintptr(unsafe.Pointer(s)), false), nil
+}
@@ -0,0 +1,409 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+
+	"github.com/twitchyliquid64/diagg/flow"
+	"github.com/twitchyliquid64/diagg/flow/gofont"
+)
+
+// arcStep is the largest angle in radians spanned by a single line segment,
+// when arcs are approximated by an ImagePainter.
+const arcStep = math.Pi / 32
+
+// curveSteps is the number of line segments a cubic Bezier curve is
+// approximated by in an ImagePainter.
+const curveSteps = 32
+
+type point struct {
+	X, Y float64
+}
+
+// subpath is a flattened sequence of points, in image coordinates.
+type subpath struct {
+	pts    []point
+	closed bool
+}
+
+// ImagePainter implements Painter by drawing to an image.RGBA in pure Go.
+// Curves are approximated by line segments, and lines are stroked with
+// round joins.
+type ImagePainter struct {
+	img *image.RGBA
+	r   *vector.Rasterizer
+
+	// Flowchart coordinates are mapped to the image by subtracting origin,
+	// then multiplying by scale.
+	scale            float64
+	originX, originY float64
+
+	src        color.RGBA
+	lineWidth  float64
+	dashes     []float64
+	dashOffset float64
+
+	path []subpath
+}
+
+// NewImagePainter returns a Painter which draws to img. The given flowchart
+// position is drawn at the top-left of the image, scaled by scale.
+func NewImagePainter(img *image.RGBA, originX, originY, scale float64) *ImagePainter {
+	size := img.Bounds().Size()
+	return &ImagePainter{
+		img:       img,
+		r:         vector.NewRasterizer(size.X, size.Y),
+		scale:     scale,
+		originX:   originX,
+		originY:   originY,
+		src:       color.RGBA{A: 0xff},
+		lineWidth: 2,
+	}
+}
+
+func (p *ImagePainter) toImage(x, y float64) point {
+	return point{X: (x - p.originX) * p.scale, Y: (y - p.originY) * p.scale}
+}
+
+func (p *ImagePainter) SetSourceRGB(r, g, b float64) { p.SetSourceRGBA(r, g, b, 1) }
+
+func (p *ImagePainter) SetSourceRGBA(r, g, b, a float64) { p.src = premultiplied(r, g, b, a) }
+
+// premultiplied converts a color with components between 0 and 1 to the
+// alpha-premultiplied form used by image.RGBA.
+func premultiplied(r, g, b, a float64) color.RGBA {
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(1, v)) }
+	c := func(v float64) uint8 { return uint8(math.Round(clamp(v) * clamp(a) * 0xff)) }
+	return color.RGBA{R: c(r), G: c(g), B: c(b), A: c(1)}
+}
+
+func (p *ImagePainter) SetLineWidth(w float64) { p.lineWidth = w }
+func (p *ImagePainter) LineWidth() float64     { return p.lineWidth }
+
+func (p *ImagePainter) SetDash(dashes []float64, offset float64) {
+	p.dashes, p.dashOffset = dashes, offset
+}
+
+func (p *ImagePainter) NewPath() { p.path = p.path[:0] }
+
+func (p *ImagePainter) MoveTo(x, y float64) {
+	p.path = append(p.path, subpath{pts: []point{p.toImage(x, y)}})
+}
+
+func (p *ImagePainter) LineTo(x, y float64) {
+	if len(p.path) == 0 || p.path[len(p.path)-1].closed {
+		p.MoveTo(x, y)
+		return
+	}
+	sp := &p.path[len(p.path)-1]
+	sp.pts = append(sp.pts, p.toImage(x, y))
+}
+
+func (p *ImagePainter) Arc(xc, yc, radius, angle1, angle2 float64) {
+	for angle2 < angle1 {
+		angle2 += 2 * math.Pi
+	}
+	steps := int(math.Max(1, math.Ceil((angle2-angle1)/arcStep)))
+	for i := 0; i <= steps; i++ {
+		a := angle1 + (angle2-angle1)*float64(i)/float64(steps)
+		p.LineTo(xc+radius*math.Cos(a), yc+radius*math.Sin(a))
+	}
+}
+
+func (p *ImagePainter) CurveTo(x1, y1, x2, y2, x3, y3 float64) {
+	if len(p.path) == 0 || p.path[len(p.path)-1].closed {
+		p.MoveTo(x1, y1)
+	}
+	sp := &p.path[len(p.path)-1]
+	var (
+		start  = sp.pts[len(sp.pts)-1]
+		c1, c2 = p.toImage(x1, y1), p.toImage(x2, y2)
+		end    = p.toImage(x3, y3)
+	)
+	for i := 1; i <= curveSteps; i++ {
+		t := float64(i) / curveSteps
+		mt := 1 - t
+		sp.pts = append(sp.pts, point{
+			X: mt*mt*mt*start.X + 3*mt*mt*t*c1.X + 3*mt*t*t*c2.X + t*t*t*end.X,
+			Y: mt*mt*mt*start.Y + 3*mt*mt*t*c1.Y + 3*mt*t*t*c2.Y + t*t*t*end.Y,
+		})
+	}
+}
+
+func (p *ImagePainter) ClosePath() {
+	if len(p.path) == 0 {
+		return
+	}
+	sp := &p.path[len(p.path)-1]
+	sp.closed = true
+	// As in Cairo, the current point returns to the start of the subpath.
+	p.path = append(p.path, subpath{pts: []point{sp.pts[0]}})
+}
+
+// Fill fills the path with the even-odd rule, as the Cairo renderers do.
+// The rasterizer only implements the non-zero rule, so when the path has
+// several subpaths, each is rasterized on its own and their coverage is
+// combined into a mask with an exclusive or.
+func (p *ImagePainter) Fill() {
+	var fill []subpath
+	for _, sp := range p.path {
+		if len(sp.pts) >= 3 {
+			fill = append(fill, sp)
+		}
+	}
+	if len(fill) == 1 {
+		p.r.Reset(p.r.Size().X, p.r.Size().Y)
+		p.addPolygon(fill[0].pts)
+		p.draw()
+	} else if len(fill) > 1 {
+		p.fillEvenOdd(fill)
+	}
+	p.NewPath()
+}
+
+func (p *ImagePainter) addPolygon(pts []point) {
+	p.r.MoveTo(float32(pts[0].X), float32(pts[0].Y))
+	for _, pt := range pts[1:] {
+		p.r.LineTo(float32(pt.X), float32(pt.Y))
+	}
+	p.r.ClosePath()
+}
+
+func (p *ImagePainter) fillEvenOdd(fill []subpath) {
+	var (
+		bounds = image.Rectangle{Max: p.r.Size()}
+		mask   = image.NewAlpha(bounds)
+		sub    = image.NewAlpha(bounds)
+	)
+	for i, sp := range fill {
+		dst := sub
+		if i == 0 {
+			dst = mask
+		}
+		p.r.Reset(bounds.Dx(), bounds.Dy())
+		p.r.DrawOp = draw.Src
+		p.addPolygon(sp.pts)
+		p.r.Draw(dst, bounds, image.Opaque, image.Point{})
+		if i == 0 {
+			continue
+		}
+		for j, c := range sub.Pix {
+			m := uint32(mask.Pix[j])
+			mask.Pix[j] = uint8(m + uint32(c) - 2*m*uint32(c)/0xff)
+		}
+	}
+	draw.DrawMask(p.img, p.img.Bounds(), image.NewUniform(p.src), image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+func (p *ImagePainter) Stroke() {
+	p.StrokePreserve()
+	p.NewPath()
+}
+
+func (p *ImagePainter) StrokePreserve() {
+	hw := p.lineWidth * p.scale / 2
+	if hw <= 0 {
+		return
+	}
+	p.r.Reset(p.r.Size().X, p.r.Size().Y)
+	for _, sp := range p.path {
+		pts := sp.pts
+		if sp.closed {
+			pts = append(pts[:len(pts):len(pts)], pts[0])
+		}
+		for _, line := range p.dash(pts) {
+			p.strokeLine(line, hw)
+		}
+	}
+	p.draw()
+}
+
+// dash splits a polyline into the visible segments of the dash pattern.
+func (p *ImagePainter) dash(pts []point) [][]point {
+	if len(p.dashes) == 0 || len(pts) < 2 {
+		return [][]point{pts}
+	}
+	var total float64
+	for _, d := range p.dashes {
+		total += d * p.scale
+	}
+	if total <= 0 {
+		return [][]point{pts}
+	}
+
+	// Find the dash, and the distance into it, at the start of the line.
+	var (
+		idx int
+		pos = math.Mod(p.dashOffset*p.scale, total)
+	)
+	if pos < 0 {
+		pos += total
+	}
+	for pos >= p.dashes[idx]*p.scale {
+		pos -= p.dashes[idx] * p.scale
+		idx = (idx + 1) % len(p.dashes)
+	}
+
+	var (
+		out     [][]point
+		current []point
+	)
+	if idx%2 == 0 {
+		current = []point{pts[0]}
+	}
+	for i := 1; i < len(pts); i++ {
+		a, b := pts[i-1], pts[i]
+		segLen := math.Hypot(b.X-a.X, b.Y-a.Y)
+		for t := 0.0; segLen > 0 && t < segLen; {
+			remaining := p.dashes[idx]*p.scale - pos
+			if t+remaining > segLen {
+				pos += segLen - t
+				break
+			}
+			t += remaining
+			at := point{X: a.X + (b.X-a.X)*t/segLen, Y: a.Y + (b.Y-a.Y)*t/segLen}
+			if idx%2 == 0 {
+				out = append(out, append(current, at))
+				current = nil
+			} else {
+				current = []point{at}
+			}
+			pos, idx = 0, (idx+1)%len(p.dashes)
+		}
+		if current != nil {
+			current = append(current, b)
+		}
+	}
+	if len(current) > 1 {
+		out = append(out, current)
+	}
+	return out
+}
+
+// strokeLine adds the outline of a polyline of the given half-width to the
+// rasterizer. Segments and joins are added with the same winding, so they
+// do not cancel where they overlap.
+func (p *ImagePainter) strokeLine(pts []point, hw float64) {
+	for i := 1; i < len(pts); i++ {
+		a, b := pts[i-1], pts[i]
+		l := math.Hypot(b.X-a.X, b.Y-a.Y)
+		if l == 0 {
+			continue
+		}
+		nx, ny := -(b.Y-a.Y)/l*hw, (b.X-a.X)/l*hw
+		p.r.MoveTo(float32(a.X-nx), float32(a.Y-ny))
+		p.r.LineTo(float32(b.X-nx), float32(b.Y-ny))
+		p.r.LineTo(float32(b.X+nx), float32(b.Y+ny))
+		p.r.LineTo(float32(a.X+nx), float32(a.Y+ny))
+		p.r.ClosePath()
+	}
+	// Joins between segments are rounded.
+	for i := 1; i < len(pts)-1; i++ {
+		c := pts[i]
+		steps := int(2 * math.Pi / arcStep)
+		p.r.MoveTo(float32(c.X+hw), float32(c.Y))
+		for s := 1; s < steps; s++ {
+			a := 2 * math.Pi * float64(s) / float64(steps)
+			p.r.LineTo(float32(c.X+hw*math.Cos(a)), float32(c.Y+hw*math.Sin(a)))
+		}
+		p.r.ClosePath()
+	}
+}
+
+func (p *ImagePainter) draw() {
+	p.r.Draw(p.img, p.img.Bounds(), image.NewUniform(p.src), image.Point{})
+}
+
+// Text draws text by filling the outlines of each glyph, in the font
+// gofont.Measure measures text in.
+func (p *ImagePainter) Text(x, y, size float64, text string) {
+	f, err := gofont.Font()
+	if err != nil {
+		return
+	}
+	var (
+		buf  sfnt.Buffer
+		ppem = fixed.Int26_6(size * p.scale * 64)
+		dot  = p.toImage(x, y)
+		prev sfnt.GlyphIndex
+	)
+	toImage := func(pt fixed.Point26_6) (float32, float32) {
+		return float32(dot.X + float64(pt.X)/64), float32(dot.Y + float64(pt.Y)/64)
+	}
+
+	p.r.Reset(p.r.Size().X, p.r.Size().Y)
+	for i, r := range text {
+		idx, err := f.GlyphIndex(&buf, r)
+		if err != nil {
+			continue
+		}
+		if i > 0 {
+			if k, err := f.Kern(&buf, prev, idx, ppem, font.HintingNone); err == nil {
+				dot.X += float64(k) / 64
+			}
+		}
+		prev = idx
+
+		segments, err := f.LoadGlyph(&buf, idx, ppem, nil)
+		if err != nil {
+			continue
+		}
+		for _, seg := range segments {
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				p.r.ClosePath()
+				p.r.MoveTo(toImage(seg.Args[0]))
+			case sfnt.SegmentOpLineTo:
+				p.r.LineTo(toImage(seg.Args[0]))
+			case sfnt.SegmentOpQuadTo:
+				bx, by := toImage(seg.Args[0])
+				cx, cy := toImage(seg.Args[1])
+				p.r.QuadTo(bx, by, cx, cy)
+			case sfnt.SegmentOpCubeTo:
+				bx, by := toImage(seg.Args[0])
+				cx, cy := toImage(seg.Args[1])
+				dx, dy := toImage(seg.Args[2])
+				p.r.CubeTo(bx, by, cx, cy, dx, dy)
+			}
+		}
+		p.r.ClosePath()
+
+		if adv, err := f.GlyphAdvance(&buf, idx, ppem, font.HintingNone); err == nil {
+			dot.X += float64(adv) / 64
+		}
+	}
+	p.draw()
+}
+
+// RenderRGBA renders the layout to a new image, without requiring cgo.
+func RenderRGBA(l *flow.Layout, a PainterAppearance, opts ExportOptions) (*image.RGBA, error) {
+	er, err := prepareExport(l, opts)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(er.width)), int(math.Ceil(er.height))))
+	if bg := opts.Background; bg[3] > 0 {
+		c := premultiplied(bg[0], bg[1], bg[2], bg[3])
+		draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	p := NewImagePainter(img, er.min[0], er.min[1], er.scale)
+	for _, cmd := range er.dl {
+		switch c := cmd.(type) {
+		case flow.DrawNodeCmd:
+			a.PaintNode(p, opts.AnimStep, exportNode{n: c.Node, nl: c.Layout})
+		case flow.DrawPadCmd:
+			a.PaintPad(p, opts.AnimStep, exportPad{p: c.Pad, pl: c.Layout})
+		case flow.DrawEdgeCmd:
+			a.PaintEdge(p, opts.AnimStep, exportEdge{e: c.Edge, from: c.FromLayout, to: c.ToLayout})
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,149 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/twitchyliquid64/diagg/flow"
+)
+
+func TestRenderRGBA(t *testing.T) {
+	var (
+		l = flow.NewLayout()
+		a = flow.NewSNode("a", "")
+		b = flow.NewSNode("b", "")
+	)
+	a.AppendSPad("", flow.SideRight, 0.5)
+	b.AppendSPad("", flow.SideLeft, 0.5)
+	if _, err := a.LinkPads(b, a.Pads()[0], b.Pads()[0]); err != nil {
+		t.Fatalf("LinkPads() failed: %v", err)
+	}
+	l.MoveNode(a, 0, 0)
+	l.MoveNode(b, 300, 0)
+
+	img, err := RenderRGBA(l, &BasicRenderer{}, ExportOptions{
+		Scale:      2,
+		Padding:    10,
+		Background: [4]float64{0, 0, 0, 1},
+	})
+	if err != nil {
+		t.Fatalf("RenderRGBA() failed: %v", err)
+	}
+
+	min, max := l.Bounds()
+	wantW, wantH := int((max[0]-min[0]+20)*2), int((max[1]-min[1]+20)*2)
+	if size := img.Bounds().Size(); size.X != wantW || size.Y != wantH {
+		t.Errorf("image size = %v, want (%d,%d)", size, wantW, wantH)
+	}
+
+	toImage := func(x, y float64) (int, int) {
+		return int((x - min[0] + 10) * 2), int((y - min[1] + 10) * 2)
+	}
+	tcs := []struct {
+		name string
+		x, y float64
+		want color.RGBA
+	}{
+		{"background", min[0] - 5, min[1] - 5, color.RGBA{A: 0xff}},
+		{"node fill", 0, 10, premultiplied(0.5, 0.1, 0.1, 1)},
+		{"edge", 150, 30, premultiplied(0.9, 0.9, 0.9, 1)},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			x, y := toImage(tc.x, tc.y)
+			if got := img.RGBAAt(x, y); got != tc.want {
+				t.Errorf("pixel at (%v,%v) = %v, want %v", tc.x, tc.y, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRenderRGBAEmpty(t *testing.T) {
+	if _, err := RenderRGBA(flow.NewLayout(), &BasicRenderer{}, ExportOptions{}); err != ErrEmptyRegion {
+		t.Errorf("RenderRGBA() err = %v, want %v", err, ErrEmptyRegion)
+	}
+}
+
+type linkTestPad struct {
+	p     flow.Pad
+	state LinkState
+}
+
+func (p linkTestPad) Pos() (float64, float64) { return 0, 0 }
+func (p linkTestPad) Pad() flow.Pad           { return p.p }
+func (p linkTestPad) LinkState() LinkState    { return p.state }
+
+func TestPaintPadLinkState(t *testing.T) {
+	pad := flow.NewSPad("", flow.NewSNode("a", ""), flow.SideLeft, 0)
+
+	tcs := []struct {
+		state LinkState
+		x, y  int
+		want  color.RGBA
+	}{
+		{LinkNone, 48, 30, color.RGBA{}},
+		{LinkCompatible, 48, 30, premultiplied(0.3, 0.85, 0.35, 1)},
+		{LinkConnected, 48, 30, premultiplied(0.45, 0.7, 1, 1)},
+		{LinkIncompatible, 30, 29, premultiplied(0.85, 0.2, 0.2, 1)},
+	}
+	for _, tc := range tcs {
+		img := image.NewRGBA(image.Rect(0, 0, 60, 60))
+		p := NewImagePainter(img, -30, -30, 1)
+		(&BasicRenderer{}).PaintPad(p, 0, linkTestPad{p: pad, state: tc.state})
+
+		if got := img.RGBAAt(tc.x, tc.y); got != tc.want {
+			t.Errorf("state %d: pixel at (%d,%d) = %v, want %v", tc.state, tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestPaintPadHole(t *testing.T) {
+	pad := flow.NewSPad("", flow.NewSNode("a", ""), flow.SideLeft, 0)
+	img := image.NewRGBA(image.Rect(0, 0, 60, 60))
+	p := NewImagePainter(img, -30, -30, 1)
+	(&BasicRenderer{}).PaintPad(p, 0, linkTestPad{p: pad})
+
+	// The inner circle of the pad is cut out of the fill.
+	if got := img.RGBAAt(30, 30); got != (color.RGBA{}) {
+		t.Errorf("pixel at pad center = %v, want transparent", got)
+	}
+	if got, want := img.RGBAAt(38, 30), premultiplied(0.5, 0.5, 0.5, 1); got != want {
+		t.Errorf("pixel on pad ring = %v, want %v", got, want)
+	}
+}
+
+type curveTestEdge struct {
+	*flow.SEdge
+	waypoints [][2]float64
+}
+
+func (e curveTestEdge) Waypoints() [][2]float64 { return e.waypoints }
+func (e curveTestEdge) Curved() bool            { return true }
+
+type edgeTestElement struct {
+	e flow.Edge
+}
+
+func (e edgeTestElement) FromPos() (float64, float64) { return 0, 0 }
+func (e edgeTestElement) ToPos() (float64, float64)   { return 100, 0 }
+func (e edgeTestElement) Edge() flow.Edge             { return e.e }
+
+func TestPaintEdgeCurved(t *testing.T) {
+	e := curveTestEdge{
+		SEdge:     flow.NewSEdge("", nil, nil),
+		waypoints: [][2]float64{{0, 80}, {100, 80}},
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 120, 100))
+	p := NewImagePainter(img, -10, -10, 1)
+	(&BasicRenderer{}).PaintEdge(p, 0, edgeTestElement{e})
+
+	// The curve peaks at 60 below the pads, and does not reach its control
+	// points.
+	if got, want := img.RGBAAt(60, 70), premultiplied(0.9, 0.9, 0.9, 1); got != want {
+		t.Errorf("pixel on curve = %v, want %v", got, want)
+	}
+	if got := img.RGBAAt(10, 90); got != (color.RGBA{}) {
+		t.Errorf("pixel at control point = %v, want transparent", got)
+	}
+}
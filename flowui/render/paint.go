@@ -0,0 +1,238 @@
+package render
+
+import (
+	"math"
+
+	"github.com/twitchyliquid64/diagg/flow"
+)
+
+// Painter is a minimal vector drawing interface, implemented for both Cairo
+// contexts and Go images. Coordinates are in flowchart units, and paths
+// and colors behave as they do in Cairo.
+type Painter interface {
+	SetSourceRGB(r, g, b float64)
+	SetSourceRGBA(r, g, b, a float64)
+	SetLineWidth(w float64)
+	LineWidth() float64
+	// SetDash sets the dash pattern used when stroking. A nil pattern draws
+	// solid lines.
+	SetDash(dashes []float64, offset float64)
+
+	NewPath()
+	MoveTo(x, y float64)
+	LineTo(x, y float64)
+	// Arc adds a circular arc to the path, connecting it to the current
+	// point if there is one.
+	Arc(xc, yc, radius, angle1, angle2 float64)
+	// CurveTo adds a cubic Bezier curve to the path, from the current point
+	// to x3, y3, using the other points as control points.
+	CurveTo(x1, y1, x2, y2, x3, y3 float64)
+	ClosePath()
+
+	Fill()
+	Stroke()
+	StrokePreserve()
+
+	// Text draws a line of text at the given font size, with its baseline
+	// starting at x, y.
+	Text(x, y, size float64, text string)
+}
+
+// PainterAppearance represents an implementation which can display a
+// flowchart using any Painter, and so works with or without cgo.
+type PainterAppearance interface {
+	PaintNode(p Painter, animStep int64, n Node)
+	PaintPad(p Painter, animStep int64, pad Pad)
+	PaintEdge(p Painter, animStep int64, e Edge)
+}
+
+// HeadlineElement describes nodes which have text labels which should
+// be rendered.
+type HeadlineElement interface {
+	NodeHeadline() string
+}
+
+// FocusableElement types are elements which can be focused, and are drawn with
+// a thicker outline if they are currently focused.
+type FocusableElement interface {
+	Active() bool
+}
+
+// HoverableElement types are elements which are drawn highlighted while the
+// mouse is over them.
+type HoverableElement interface {
+	Hovered() bool
+}
+
+// coloredDecorator is implemented by node decorators which provide a fill
+// color.
+type coloredDecorator interface {
+	NodeColor() (float64, float64, float64)
+}
+
+// BasicRenderer draws flowcharts with a simple, flat appearance.
+type BasicRenderer struct{}
+
+func (r *BasicRenderer) isFocused(n interface{}) bool {
+	if fe, ok := n.(FocusableElement); ok {
+		return fe.Active()
+	}
+	return false
+}
+
+func (r *BasicRenderer) linkState(n interface{}) LinkState {
+	if lt, ok := n.(LinkTargetElement); ok {
+		return lt.LinkState()
+	}
+	return LinkNone
+}
+
+func (r *BasicRenderer) isHovered(n interface{}) bool {
+	if he, ok := n.(HoverableElement); ok {
+		return he.Hovered()
+	}
+	return false
+}
+
+// PaintNode implements PainterAppearance.
+func (r *BasicRenderer) PaintNode(p Painter, animStep int64, n Node) {
+	var (
+		node                     = n.Node()
+		x, y             float64 = n.Pos()
+		w, h             float64 = node.Size()
+		hw, hh           float64 = w / 2, h / 2
+		sub, borderWidth float64 = 2, 2
+	)
+	if r.isFocused(n) {
+		borderWidth = 6
+	}
+
+	p.SetSourceRGB(1, 1, 1)
+	p.SetLineWidth(borderWidth)
+	roundedRect(p, x-hw, y-hh, w-sub, h-sub, 2)
+	p.StrokePreserve()
+	p.SetSourceRGB(0.5, 0.1, 0.1)
+	if dec, isDec := node.(DecoratedNode); isDec {
+		if cd, ok := dec.NodeDecorator().(coloredDecorator); ok {
+			p.SetSourceRGB(cd.NodeColor())
+		}
+	}
+	p.Fill()
+
+	if hln, ok := node.(HeadlineElement); ok {
+		p.SetSourceRGB(1, 1, 1)
+		p.Text(x-hw+7, y-hh+18, flow.HeadlineFontSize, hln.NodeHeadline())
+	}
+}
+
+// PaintPad implements PainterAppearance.
+func (r *BasicRenderer) PaintPad(p Painter, animStep int64, pad Pad) {
+	var (
+		fp               = pad.Pad()
+		x, y     float64 = pad.Pos()
+		dia, _   float64 = fp.Size()
+		focused          = r.isFocused(pad)
+		state            = r.linkState(pad)
+		cr, g, b float64 = 0.5, 0.5, 0.5
+	)
+
+	if cp, hasColor := fp.(ColoredPad); hasColor {
+		cr, g, b = cp.PadColor()
+	}
+
+	switch {
+	case focused:
+		cr *= 1.3
+		g *= 1.3
+		b *= 1.3
+	case state == LinkIncompatible:
+		cr *= 0.4
+		g *= 0.4
+		b *= 0.4
+	}
+
+	p.SetSourceRGB(cr, g, b)
+	p.NewPath()
+	p.Arc(x, y, dia/2-1, -math.Pi, math.Pi)
+	p.ClosePath()
+	p.SetLineWidth(2)
+	p.MoveTo(x, y)
+	p.Arc(x, y, dia/4-1, -math.Pi, math.Pi)
+	p.ClosePath()
+	p.Fill()
+
+	// While a link is being dragged, pads are marked by whether the link
+	// can be made to them.
+	switch state {
+	case LinkCompatible:
+		p.SetSourceRGB(0.3, 0.85, 0.35)
+		padRing(p, x, y, dia/2+dia/4)
+	case LinkConnected:
+		p.SetSourceRGB(0.45, 0.7, 1)
+		padRing(p, x, y, dia/2+dia/4)
+	case LinkIncompatible:
+		d := dia / 4
+		p.SetSourceRGB(0.85, 0.2, 0.2)
+		p.SetLineWidth(2)
+		p.MoveTo(x-d, y+d)
+		p.LineTo(x+d, y-d)
+		p.Stroke()
+	}
+
+	if focused {
+		p.SetLineWidth(2)
+		p.SetDash([]float64{4, 4}, float64(-(animStep >> 15)))
+		p.NewPath()
+		p.Arc(x, y, dia/2+dia/10, -math.Pi, math.Pi)
+		p.ClosePath()
+		p.Stroke()
+		p.SetDash(nil, 0)
+	}
+}
+
+// padRing strokes a solid ring around a pad.
+func padRing(p Painter, x, y, radius float64) {
+	p.SetLineWidth(2)
+	p.NewPath()
+	p.Arc(x, y, radius, -math.Pi, math.Pi)
+	p.ClosePath()
+	p.Stroke()
+}
+
+// PaintEdge implements PainterAppearance.
+func (r *BasicRenderer) PaintEdge(p Painter, animStep int64, e Edge) {
+	var (
+		sx, sy   float64 = e.FromPos()
+		ex, ey   float64 = e.ToPos()
+		cr, g, b         = 0.9, 0.9, 0.9
+		width            = p.LineWidth()
+	)
+	defer p.SetLineWidth(width)
+
+	switch {
+	case r.isFocused(e):
+		cr, g, b = 0.45, 0.7, 1
+		p.SetLineWidth(6)
+	case r.isHovered(e):
+		cr, g, b = 1, 1, 1
+		p.SetLineWidth(width + 2)
+	}
+
+	p.SetSourceRGB(cr, g, b)
+	p.MoveTo(sx, sy)
+	if wps, curved := flow.CurveWaypoints(e.Edge()); curved {
+		pts := append(wps[:len(wps):len(wps)], [2]float64{ex, ey})
+		for i := 0; i+2 < len(pts); i += 3 {
+			p.CurveTo(pts[i][0], pts[i][1], pts[i+1][0], pts[i+1][1], pts[i+2][0], pts[i+2][1])
+		}
+		p.Stroke()
+		return
+	}
+	if re, ok := e.Edge().(flow.RoutedEdge); ok {
+		for _, wp := range re.Waypoints() {
+			p.LineTo(wp[0], wp[1])
+		}
+	}
+	p.LineTo(ex, ey)
+	p.Stroke()
+}
@@ -27,3 +27,27 @@ type Edge interface {
 	ToPos() (float64, float64)
 	Edge() flow.Edge
 }
+
+// LinkState classifies a pad while the user is dragging a new link, by
+// whether the link could be made to it.
+type LinkState uint8
+
+// Valid LinkState values.
+const (
+	// LinkNone indicates no link is being dragged, or the pad is where the
+	// link starts.
+	LinkNone LinkState = iota
+	// LinkCompatible indicates the link can be made to the pad.
+	LinkCompatible
+	// LinkIncompatible indicates the link cannot be made to the pad.
+	LinkIncompatible
+	// LinkConnected indicates the pad is already linked to the pad the
+	// link starts from.
+	LinkConnected
+)
+
+// LinkTargetElement describes pads which are classified while a link is
+// being dragged.
+type LinkTargetElement interface {
+	LinkState() LinkState
+}
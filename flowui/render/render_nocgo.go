@@ -3,6 +3,7 @@
 package render
 
 // NodeDecorator describes types which provide information about how to
-// draw nodes.
+// draw nodes. Without cgo, icons and overlays cannot be drawn, but a
+// decorator may still provide a NodeColor() method.
 type NodeDecorator interface {
 }
@@ -8,20 +8,10 @@ import (
 	"github.com/gotk3/gotk3/gtk"
 )
 
+// DrawFunc draws additional content over a node centered at x, y. The
+// drawing area is nil when the flowchart is rendered headlessly.
 type DrawFunc func(da *gtk.DrawingArea, cr *cairo.Context, animStep int64, x, y float64)
 
-// HeadlineElement describes nodes which have text labels which should
-// be rendered.
-type HeadlineElement interface {
-	NodeHeadline() string
-}
-
-// FocusableElement types are elements which can be focused, and are drawn with
-// a thicker outline if they are currently focused.
-type FocusableElement interface {
-	Active() bool
-}
-
 // NodeDecorator describes types which provide information about how to
 // draw nodes.
 type NodeDecorator interface {
@@ -31,82 +21,43 @@ type NodeDecorator interface {
 }
 
 // Appearance represents an implementation which can display a flowchart.
+//
+// The drawing area passed to each method is the widget being drawn to, or
+// nil when rendering without a widget, such as when exporting an image.
+// Implementations must not assume it is present.
 type Appearance interface {
 	DrawNode(da *gtk.DrawingArea, cr *cairo.Context, animStep int64, n Node)
 	DrawPad(da *gtk.DrawingArea, cr *cairo.Context, animStep int64, p Pad)
 	DrawEdge(da *gtk.DrawingArea, cr *cairo.Context, animStep int64, e Edge)
 }
 
-type BasicRenderer struct{}
+func (r *BasicRenderer) DrawNode(da *gtk.DrawingArea, cr *cairo.Context, animStep int64, n Node) {
+	r.PaintNode(NewCairoPainter(cr), animStep, n)
 
-func (r *BasicRenderer) isFocused(n interface{}) bool {
-	if fe, ok := n.(FocusableElement); ok {
-		return fe.Active()
+	dec, isDec := n.Node().(DecoratedNode)
+	if !isDec {
+		return
 	}
-	return false
-}
-
-func (r *BasicRenderer) DrawNode(da *gtk.DrawingArea, cr *cairo.Context, animStep int64, n Node) {
 	var (
-		node                     = n.Node()
-		dec, isDec               = node.(DecoratedNode)
-		x, y             float64 = n.Pos()
-		w, h             float64 = node.Size()
-		hw, hh           float64 = w / 2, h / 2
-		sub, borderWidth float64 = 2, 2
+		x, y = n.Pos()
+		nd   = dec.NodeDecorator()
+		pb   = nd.NodeIcon()
 	)
-	if r.isFocused(n) {
-		borderWidth = 6
-	}
-
+	px, py := x-float64(pb.GetWidth())/2, y-float64(pb.GetHeight())/2
+	cr.Translate(px, py)
+	//cr.SetAntialias(cairo.ANTIALIAS_NONE)
+	gtk.GdkCairoSetSourcePixBuf(cr, pb, 0, 0)
+	cr.Paint()
+	cr.Translate(-px, -py)
+	//cr.SetAntialias(cairo.ANTIALIAS_DEFAULT)
 	cr.SetSourceRGB(1, 1, 1)
-	cr.SetLineWidth(borderWidth)
-	roundedRect(da, cr, x-hw, y-hh, w-sub, h-sub, 2)
-	cr.StrokePreserve()
-	if isDec {
-		r, g, b := dec.NodeDecorator().NodeColor()
-		cr.SetSourceRGB(r, g, b)
-	} else {
-		cr.SetSourceRGB(0.5, 0.1, 0.1)
-	}
-	cr.Fill()
-
-	if hln, ok := node.(HeadlineElement); ok {
-		cr.MoveTo(x-hw+7, y-hh+18)
-		cr.SetSourceRGB(1, 1, 1)
-		cr.SetFontSize(16)
-		cr.ShowText(hln.NodeHeadline())
-		cr.Fill()
-	}
 
-	if isDec {
-		nd := dec.NodeDecorator()
-		pb := nd.NodeIcon()
-		px, py := x-float64(pb.GetWidth())/2, y-float64(pb.GetHeight())/2
-		cr.Translate(px, py)
-		//cr.SetAntialias(cairo.ANTIALIAS_NONE)
-		gtk.GdkCairoSetSourcePixBuf(cr, pb, 0, 0)
-		cr.Paint()
-		cr.Translate(-px, -py)
-		//cr.SetAntialias(cairo.ANTIALIAS_DEFAULT)
-		cr.SetSourceRGB(1, 1, 1)
-
-		d := nd.NodeOverlayDraw()
-		if d != nil {
-			d(da, cr, animStep, x, y)
-		}
+	d := nd.NodeOverlayDraw()
+	if d != nil {
+		d(da, cr, animStep, x, y)
 	}
 }
 
-func (renderer *BasicRenderer) DrawEdge(da *gtk.DrawingArea, cr *cairo.Context, animStep int64, e Edge) {
-	var (
-		sx, sy  float64 = e.FromPos()
-		ex, ey  float64 = e.ToPos()
-		r, g, b         = 0.9, 0.9, 0.9
-	)
-
-	cr.SetSourceRGB(r, g, b)
-	cr.MoveTo(sx, sy)
-	cr.LineTo(ex, ey)
-	cr.Stroke()
+func (r *BasicRenderer) DrawEdge(da *gtk.DrawingArea, cr *cairo.Context, animStep int64, e Edge) {
+	r.PaintEdge(NewCairoPainter(cr), animStep, e)
 }
@@ -0,0 +1,139 @@
+package flowui
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gotk3/gotk3/cairo"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/twitchyliquid64/diagg/hit"
+)
+
+// flowObject returns the flowchart element represented by a hit target.
+func flowObject(t hit.TestableObj) interface{} {
+	switch t := t.(type) {
+	case *rectNode:
+		return t.Node()
+	case *circPad:
+		return t.Pad()
+	case *lineEdge:
+		return t.Edge()
+	case nil:
+		return nil
+	default:
+		panic(fmt.Sprintf("cannot handle type: %T", t))
+	}
+}
+
+func (fcv *FlowchartView) isSelected(t hit.TestableObj) bool {
+	for _, s := range fcv.selection {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
+
+// setSelection replaces the selection with the given elements.
+func (fcv *FlowchartView) setSelection(sel []hit.TestableObj) {
+	for _, s := range fcv.selection {
+		fcv.model.SetTargetActive(s, false)
+	}
+	fcv.selection = fcv.selection[:0]
+	for _, s := range sel {
+		fcv.addSelected(s)
+	}
+}
+
+func (fcv *FlowchartView) addSelected(t hit.TestableObj) {
+	if t == nil || fcv.isSelected(t) {
+		return
+	}
+	fcv.selection = append(fcv.selection, t)
+	fcv.model.SetTargetActive(t, true)
+}
+
+func (fcv *FlowchartView) deselect(t hit.TestableObj) {
+	for i, s := range fcv.selection {
+		if s == t {
+			fcv.selection = append(fcv.selection[:i], fcv.selection[i+1:]...)
+			fcv.model.SetTargetActive(t, false)
+			break
+		}
+	}
+	if fcv.lmc.target == t {
+		fcv.lmc.target = nil
+	}
+}
+
+// toggleSelected adds the element to the selection, or removes it if it
+// was already selected.
+func (fcv *FlowchartView) toggleSelected(t hit.TestableObj) {
+	if fcv.isSelected(t) {
+		fcv.deselect(t)
+		return
+	}
+	fcv.addSelected(t)
+}
+
+// selectionChanged notifies listeners of the current selection.
+func (fcv *FlowchartView) selectionChanged() {
+	fcv.da.Emit("flow-selection")
+	if fcv.selectionCB != nil {
+		fcv.selectionCB(fcv.GetSelectionSet())
+	}
+}
+
+// beginGroupMove records the position of all selected nodes, so they can
+// be moved together relative to where the drag started.
+func (fcv *FlowchartView) beginGroupMove() {
+	fcv.groupStart = make(map[*rectNode][2]float64, len(fcv.selection))
+	for _, s := range fcv.selection {
+		if rn, isNode := s.(*rectNode); isNode {
+			x, y := fcv.model.TargetPos(rn)
+			fcv.groupStart[rn] = [2]float64{x, y}
+		}
+	}
+}
+
+// moveGroup moves all nodes which were selected when the drag started by
+// the given offset from their starting positions. The node being dragged
+// is snapped, and the rest of the group follows it.
+func (fcv *FlowchartView) moveGroup(dx, dy float64, mods gdk.ModifierType) {
+	if rn, isNode := fcv.lmc.target.(*rectNode); isNode {
+		if start, ok := fcv.groupStart[rn]; ok {
+			x, y := fcv.snapPosition(rn, start[0]+dx, start[1]+dy, mods)
+			dx, dy = x-start[0], y-start[1]
+		}
+	}
+	for rn, start := range fcv.groupStart {
+		fcv.model.MoveTarget(rn, start[0]+dx, start[1]+dy)
+	}
+}
+
+// marqueeBounds returns the rectangle being dragged out to select elements,
+// in flowchart coordinates.
+func (fcv *FlowchartView) marqueeBounds() (min, max hit.Point) {
+	return hit.Point{X: math.Min(fcv.lmc.ObjX, fcv.lmc.DragX), Y: math.Min(fcv.lmc.ObjY, fcv.lmc.DragY)},
+		hit.Point{X: math.Max(fcv.lmc.ObjX, fcv.lmc.DragX), Y: math.Max(fcv.lmc.ObjY, fcv.lmc.DragY)}
+}
+
+func (fcv *FlowchartView) drawMarquee(da *gtk.DrawingArea, cr *cairo.Context) {
+	min, max := fcv.marqueeBounds()
+	cr.SetLineWidth(1 / fcv.zoom)
+	cr.Rectangle(min.X, min.Y, max.X-min.X, max.Y-min.Y)
+	cr.SetSourceRGBA(0.4, 0.6, 1, 0.15)
+	cr.FillPreserve()
+	cr.SetSourceRGBA(0.4, 0.6, 1, 0.8)
+	cr.Stroke()
+}
+
+// finishMarquee adds all nodes within the marquee to the selection.
+func (fcv *FlowchartView) finishMarquee() {
+	min, max := fcv.marqueeBounds()
+	for _, n := range fcv.model.QueryNodes(min, max) {
+		fcv.addSelected(n)
+	}
+	fcv.marquee = false
+}
@@ -10,7 +10,17 @@ import (
 	"github.com/twitchyliquid64/diagg/hit"
 )
 
-const posQuant = 16
+const (
+	// dragThreshold is the distance in pixels the mouse must move before a
+	// node starts being dragged.
+	dragThreshold = 16
+	// padSnapDist is the distance in pixels within which a link being
+	// dragged snaps to the nearest pad.
+	padSnapDist = 30
+	// edgeHitWidth is the distance in pixels from an edge within which
+	// the edge is considered to be hit.
+	edgeHitWidth = 6
+)
 
 // dragState tracks the state of mouse movement for a mouse button.
 type dragState struct {
@@ -44,12 +54,32 @@ type Overlay interface {
 	Draw(da *gtk.DrawingArea, cr *cairo.Context)
 }
 
+// KeyHandler can be implemented by overlays which handle key presses. If
+// the event should be intercepted and not processed by the flowchart,
+// HandleKeypress should return true.
+type KeyHandler interface {
+	HandleKeypress(*gdk.EventKey) bool
+}
+
 type FlowchartView struct {
 	da *gtk.DrawingArea
 
 	lmc         dragState // left mouse click, like moving a node.
 	pan         dragState
 	hoverTarget *circPad
+	hoverEdge   *lineEdge
+	// linkFrom is the pad the pads were last classified against, while a
+	// link is being dragged.
+	linkFrom *circPad
+
+	// selection holds the selected elements, in the order they were selected.
+	selection []hit.TestableObj
+	// groupStart holds the positions of the selected nodes when a drag began.
+	groupStart map[*rectNode][2]float64
+	// marquee is true while a selection rectangle is being dragged out.
+	marquee bool
+	// reconnect is set while the end of an edge is being dragged.
+	reconnect *reconnectState
 
 	animHnd       int
 	animStartTime int64
@@ -59,6 +89,10 @@ type FlowchartView struct {
 	offsetX float64
 	offsetY float64
 	zoom    float64
+	// Limits of the zoom level. A maxZoom of zero means no limit.
+	minZoom, maxZoom float64
+	// transition is set while the viewport is being animated.
+	transition *viewTransition
 
 	// width/height of the drawing area.
 	width, height int
@@ -66,6 +100,23 @@ type FlowchartView struct {
 	model         Model
 	overlays      []Overlay
 	doublePressCB func(t interface{}, x, y float64) // Callback for double-click.
+	selectionCB   func(sel []interface{})           // Callback for selection changes.
+	reconnectCB   func(EdgeReconnect)               // Callback for reconnected edges.
+	menuProvider  ContextMenuProvider               // Provides right-click menus.
+	menu          *gtk.Menu                         // Most recently shown context menu.
+	spaceHeld     bool                              // Space held, to pan with the left button.
+	keymap        Keymap                            // Key bindings used when focused.
+	grid          GridSettings
+	hovered       hit.TestableObj // Element under the mouse.
+	hoverCB       HoverCallback   // Callback for changes to the hovered element.
+	tooltip       tooltipState
+	tooltipDelay  uint               // Milliseconds before a tooltip is shown.
+	editor        *headlineEditor    // Headline being edited, if any.
+	headlineCB    func(HeadlineEdit) // Callback for edited headlines.
+	dropTargets   []DropTarget       // Types of data accepted by drops.
+	dropCB        DropCallback       // Callback for dropped data.
+	dropConnected bool               // Whether drag-data-received is connected.
+	errorCB       func(error)        // Callback for errors handling input.
 }
 
 func (fcv *FlowchartView) onCanvasConfigureEvent(da *gtk.DrawingArea, event *gdk.Event) bool {
@@ -81,6 +132,7 @@ func (fcv *FlowchartView) onCanvasConfigureEvent(da *gtk.DrawingArea, event *gdk
 func (fcv *FlowchartView) onCanvasDrawEvent(da *gtk.DrawingArea, cr *cairo.Context) bool {
 	cr.SetSourceRGB(0.12, 0.12, 0.12)
 	cr.Paint()
+	fcv.drawGrid(cr)
 	cr.SetLineWidth(5)
 	cr.SetFillRule(cairo.FILL_RULE_EVEN_ODD)
 
@@ -90,10 +142,11 @@ func (fcv *FlowchartView) onCanvasDrawEvent(da *gtk.DrawingArea, cr *cairo.Conte
 		cr.Scale(fcv.zoom, fcv.zoom)
 	}
 	fcv.model.Draw(da, cr, fcv.animTime-fcv.animStartTime)
-	if fcv.lmc.dragging {
-		if rn, ok := fcv.lmc.target.(*circPad); ok {
-			fcv.drawDragLink(da, cr, rn)
-		}
+	if startPad := fcv.draggingFromPad(); startPad != nil {
+		fcv.drawDragLink(da, cr, startPad)
+	}
+	if fcv.marquee {
+		fcv.drawMarquee(da, cr)
 	}
 	cr.Restore()
 
@@ -102,6 +155,7 @@ func (fcv *FlowchartView) onCanvasDrawEvent(da *gtk.DrawingArea, cr *cairo.Conte
 		o.Draw(da, cr)
 	}
 	cr.Restore()
+	fcv.drawTooltip(cr)
 
 	fcv.writeDebugStr(da, cr, fmt.Sprintf("Zoom: %.2f", fcv.zoom), 4)
 	fcv.writeDebugStr(da, cr, fmt.Sprintf("Pos: %3.2f, %3.2f", fcv.offsetX, fcv.offsetY), 3)
@@ -113,10 +167,14 @@ func (fcv *FlowchartView) onCanvasDrawEvent(da *gtk.DrawingArea, cr *cairo.Conte
 
 func (fcv *FlowchartView) drawDragLink(da *gtk.DrawingArea, cr *cairo.Context, startPad *circPad) {
 	x, y := startPad.Pos()
+	endX, endY := fcv.lmc.DragX, fcv.lmc.DragY
+	if fcv.hoverTarget != nil {
+		endX, endY = fcv.hoverTarget.Pos()
+	}
 	cr.SetLineWidth(2)
 	cr.SetSourceRGB(1, 1, 1)
 	cr.MoveTo(x, y)
-	cr.LineTo(fcv.lmc.DragX, fcv.lmc.DragY)
+	cr.LineTo(endX, endY)
 	cr.Stroke()
 }
 
@@ -140,7 +198,6 @@ func (fcv *FlowchartView) onMotionEvent(area *gtk.DrawingArea, event *gdk.Event)
 	}
 
 	x, y := evt.MotionVal()
-	rebuildHits := false
 
 	// Handle moving the entire view.
 	if fcv.pan.dragging {
@@ -148,46 +205,71 @@ func (fcv *FlowchartView) onMotionEvent(area *gtk.DrawingArea, event *gdk.Event)
 		fcv.offsetY = -(fcv.pan.StartY - y)
 	}
 
-	// Handle moving around nodes & dragging a pad connection.
-	if fcv.lmc.dragging && fcv.lmc.target != nil {
+	// Handle moving around nodes, dragging a pad connection, or dragging out
+	// a selection rectangle.
+	if fcv.lmc.dragging && (fcv.lmc.target != nil || fcv.marquee) {
+		// The distance dragged is measured in pixels, like the threshold.
+		fcv.lmc.sqDist = math.Pow(fcv.lmc.StartX-x, 2) + math.Pow(fcv.lmc.StartY-y, 2)
+
 		// Update the end position for the drag.
 		x, y := fcv.lmc.ObjX-(fcv.lmc.StartX-x)/fcv.zoom, fcv.lmc.ObjY-(fcv.lmc.StartY-y)/fcv.zoom
 		fcv.lmc.DragX, fcv.lmc.DragY = x, y
 
-		// If the starting element was a node, we need to handle moving it.
-		if _, isNode := fcv.lmc.target.(*rectNode); isNode {
-			// Either we stay in the same position, or if the diff is greater than the
-			// position quanta, we move the target.
-			fcv.lmc.sqDist = math.Pow(fcv.lmc.StartX-x, 2) + math.Pow(fcv.lmc.StartY-y, 2)
-			if fcv.lmc.sqDist > (posQuant * posQuant) {
-				// Quantize the position.
-				x, y = quantizeCoords(x, y)
-				fcv.model.MoveTarget(fcv.lmc.target, x, y)
-				rebuildHits = true
+		// If the starting element was a node, we need to handle moving it along
+		// with the rest of the selection.
+		if rn, isNode := fcv.lmc.target.(*rectNode); isNode && fcv.isSelected(rn) {
+			// Either we stay in the same position, or if the mouse has moved
+			// further than the threshold, we move the selected nodes.
+			if fcv.lmc.sqDist > (dragThreshold * dragThreshold) {
+				fcv.moveGroup(x-fcv.lmc.ObjX, y-fcv.lmc.ObjY, evt.State())
 			}
 		}
 	}
 
-	// Handle hovering over pads while dragging from another pad.
+	// Track the element under the mouse, when not dragging.
+	if !fcv.lmc.dragging && !fcv.pan.dragging {
+		fcv.updateHover(x, y)
+	} else {
+		fcv.clearHover()
+	}
+
+	// Handle hovering over pads while dragging from another pad. The nearest
+	// pad within range is used, so the link snaps to it.
 	if start := fcv.draggingFromPad(); start != nil {
-		hoverTarget := fcv.model.HitTest(fcv.drawCoordsToFlow(x, y))
-		if fcv.hoverTarget != start && fcv.hoverTarget != nil && fcv.hoverTarget != hoverTarget {
-			fcv.clearHoverTarget()
-		}
-		if endPad, hoversPad := hoverTarget.(*circPad); hoversPad {
-			fcv.hoverTarget = endPad
-			endPad.active = true
+		if fcv.linkFrom != start {
+			fcv.classifyPads(start)
 		}
+		fcv.updateSnapTarget(start, x, y)
 	}
 
-	if rebuildHits {
-		// TODO: Instead of rebuilding completely, implement scanning the hit tester
-		// to update the single value being moved.
-		fcv.model.buildModel()
-	}
 	fcv.da.QueueDraw()
 }
 
+// updateSnapTarget sets the hover target to the nearest pad which a link
+// being dragged from start could snap to.
+func (fcv *FlowchartView) updateSnapTarget(start *circPad, x, y float64) {
+	endPad := fcv.model.NearestLinkablePad(fcv.drawCoordsToFlow(x, y), start, padSnapDist/fcv.zoom)
+	if fcv.hoverTarget != endPad {
+		fcv.clearHoverTarget()
+	}
+	if endPad != nil {
+		fcv.hoverTarget = endPad
+		endPad.active = true
+	}
+}
+
+func (fcv *FlowchartView) setHoverEdge(e *lineEdge) {
+	if fcv.hoverEdge == e {
+		return
+	}
+	if fcv.hoverEdge != nil {
+		fcv.hoverEdge.hover = false
+	}
+	if fcv.hoverEdge = e; e != nil {
+		e.hover = true
+	}
+}
+
 func (fcv *FlowchartView) clearHoverTarget() {
 	if fcv.hoverTarget != nil {
 		fcv.hoverTarget.active = false
@@ -195,12 +277,6 @@ func (fcv *FlowchartView) clearHoverTarget() {
 	}
 }
 
-func quantizeCoords(x, y float64) (float64, float64) {
-	x, y = float64(int(x/posQuant)*posQuant), float64(int(y/posQuant)*posQuant)
-	x, y = x+(posQuant/2), y+(posQuant/2)
-	return x, y
-}
-
 func (fcv *FlowchartView) onPressEvent(area *gtk.DrawingArea, event *gdk.Event) {
 	for _, o := range fcv.overlays {
 		if o.HandlePressEvent(event, true) {
@@ -208,52 +284,146 @@ func (fcv *FlowchartView) onPressEvent(area *gtk.DrawingArea, event *gdk.Event)
 		}
 	}
 
+	fcv.hideTooltip()
 	evt := gdk.EventButtonNewFromEvent(event)
 	x, y := evt.MotionVal()
 	switch evt.Type() {
 	case gdk.EVENT_2BUTTON_PRESS:
 		switch evt.Button() {
 		case 1: // left mouse button.
+			// Nodes with editable headlines are edited in place.
+			if rn, isNode := fcv.model.HitTest(fcv.drawCoordsToFlow(x, y)).(*rectNode); isNode && fcv.beginHeadlineEdit(rn) {
+				break
+			}
 			if fcv.doublePressCB != nil {
 				fcv.doublePressCB(fcv.GetSelection(), x, y)
 			}
 		}
 	case gdk.EVENT_BUTTON_PRESS:
+		fcv.commitHeadlineEdit()
+		fcv.da.GrabFocus()
 		switch evt.Button() {
 		case 1: // left mouse button.
-			fcv.model.SetTargetActive(fcv.lmc.target, false)
+			// When context menus are enabled, the view is panned by dragging
+			// with space held.
+			if fcv.spaceHeld {
+				fcv.startPan(x, y)
+				break
+			}
+
 			fcv.lmc.dragging = true
 			fcv.lmc.StartX, fcv.lmc.StartY = x, y
 			tp := fcv.drawCoordsToFlow(x, y)
+			state := gdk.ModifierType(evt.State())
+
+			// Alt+click cycles through stacked elements under the mouse, starting
+			// below the current selection.
+			var target hit.TestableObj
+			if state&gdk.GDK_MOD1_MASK != 0 {
+				target = fcv.model.HitTestBelow(tp, fcv.lmc.target)
+			} else {
+				target = fcv.model.HitTest(tp)
+			}
+			fcv.lmc.target = target
+
+			// Shift or Ctrl clicks add to the selection rather than replacing it.
+			additive := state&(gdk.GDK_SHIFT_MASK|gdk.GDK_CONTROL_MASK) != 0
+			switch {
+			case target == nil:
+				// Clicking empty space starts a selection rectangle.
+				if !additive {
+					fcv.setSelection(nil)
+				}
+				fcv.marquee = true
+				fcv.lmc.ObjX, fcv.lmc.ObjY = tp.X, tp.Y
+				fcv.lmc.DragX, fcv.lmc.DragY = tp.X, tp.Y
+			case additive:
+				fcv.toggleSelected(target)
+			case !fcv.isSelected(target):
+				fcv.setSelection([]hit.TestableObj{target})
+			}
 
-			// If we clicked on a node/pad, update the selection state and set the
-			// element as active.
-			if fcv.lmc.target = fcv.model.HitTest(tp); fcv.lmc.target != nil {
-				fcv.lmc.ObjX, fcv.lmc.ObjY = fcv.model.TargetPos(fcv.lmc.target)
+			// If we clicked near the end of an edge, prepare to drag that end to
+			// another pad.
+			if e, isEdge := target.(*lineEdge); isEdge && !additive && fcv.beginReconnect(e, tp) {
+				fcv.lmc.ObjX, fcv.lmc.ObjY = tp.X, tp.Y
+				fcv.lmc.DragX, fcv.lmc.DragY = tp.X, tp.Y
+				fcv.ensureAnimating()
+			} else if target != nil {
+				// If we clicked on a node/pad, prepare to drag it and the rest of the
+				// selection.
+				fcv.lmc.ObjX, fcv.lmc.ObjY = fcv.model.TargetPos(target)
 				fcv.lmc.DragX, fcv.lmc.DragY = fcv.lmc.ObjX, fcv.lmc.ObjY
-				fcv.model.SetTargetActive(fcv.lmc.target, true)
+				fcv.beginGroupMove()
 
 				// If the target is a pad, we should animate the hover circles.
-				if _, isPad := fcv.lmc.target.(*circPad); isPad {
+				if _, isPad := target.(*circPad); isPad {
 					fcv.ensureAnimating()
 				}
 			}
-			fcv.da.Emit("flow-selection")
+			fcv.selectionChanged()
 			fcv.da.QueueDraw()
 
 		case 2, 3: // middle,right button
-			fcv.pan.dragging = true
-			fcv.pan.StartX, fcv.pan.StartY = x-fcv.offsetX, y-fcv.offsetY
+			if evt.Button() == 3 && fcv.menuProvider != nil {
+				if err := fcv.showContextMenu(event, x, y); err != nil {
+					fcv.reportError(err)
+				}
+				break
+			}
+			fcv.startPan(x, y)
 		}
 	}
 }
 
+// classifyPads marks every pad by whether the link being dragged from start
+// could be made to it.
+func (fcv *FlowchartView) classifyPads(start *circPad) {
+	fcv.linkFrom = start
+	if rs := fcv.reconnect; rs != nil {
+		fcv.model.classifyPads(start, rs.movingFrom, rs.edge.E)
+	} else {
+		fcv.model.classifyPads(start, false, nil)
+	}
+}
+
+func (fcv *FlowchartView) clearPadClasses() {
+	if fcv.linkFrom != nil {
+		fcv.linkFrom = nil
+		fcv.model.clearPadClasses()
+	}
+}
+
+// linkFailed reports a failure to link pads together, showing the reason
+// at the position on the drawing area where the link was dropped.
+func (fcv *FlowchartView) linkFailed(err error, x, y float64) {
+	fcv.showMessage("Cannot link: "+err.Error(), x, y)
+}
+
+// reportError passes an error which occurred while handling user input to
+// the error callback, if one is set.
+func (fcv *FlowchartView) reportError(err error) {
+	if fcv.errorCB != nil {
+		fcv.errorCB(err)
+	}
+}
+
+func (fcv *FlowchartView) startPan(x, y float64) {
+	fcv.transition = nil
+	fcv.pan.dragging = true
+	fcv.pan.StartX, fcv.pan.StartY = x-fcv.offsetX, y-fcv.offsetY
+}
+
 // draggingFromPad returns the *circPad of the pad which the user is dragging
-// from, or nil if the user is not currently dragging from a pad.
+// from, or nil if the user is not currently dragging from a pad. While the end
+// of an edge is being dragged, this is the pad at the other end.
 func (fcv *FlowchartView) draggingFromPad() *circPad {
 	if !fcv.lmc.dragging {
 		return nil
 	}
+	if fcv.reconnect != nil {
+		return fcv.reconnect.fixed
+	}
 	if startPad, ok := fcv.lmc.target.(*circPad); ok {
 		return startPad
 	}
@@ -269,22 +439,32 @@ func (fcv *FlowchartView) onReleaseEvent(area *gtk.DrawingArea, event *gdk.Event
 
 	evt := gdk.EventButtonNewFromEvent(event)
 	x, y := gdk.EventMotionNewFromEvent(event).MotionVal()
-	releaseTarget := fcv.model.HitTest(fcv.drawCoordsToFlow(x, y))
 
 	switch evt.Button() {
 	case 1:
-		// Handle the user dragging from one pad to the other.
-		if startPad := fcv.draggingFromPad(); startPad != nil && releaseTarget != nil {
-			if endPad, ok := releaseTarget.(*circPad); ok && endPad != startPad {
+		// Handle the user dragging from one pad to the other, linking to the
+		// pad the drag snapped to. If the user was dragging the end of an
+		// edge, the edge is reconnected instead.
+		if fcv.reconnect != nil {
+			fcv.finishReconnect(x, y)
+		} else if startPad := fcv.draggingFromPad(); startPad != nil {
+			fcv.updateSnapTarget(startPad, x, y)
+			if endPad := fcv.hoverTarget; endPad != nil {
 				if err := fcv.model.OnUserLinksPads(startPad, endPad); err != nil {
-					fmt.Printf("failed to link pads: %v\n", err)
+					fcv.linkFailed(err, x, y)
 				} else {
 					fcv.da.Emit("flow-created-link")
 				}
 			}
 		}
+		if fcv.marquee {
+			fcv.finishMarquee()
+			fcv.selectionChanged()
+		}
 		fcv.lmc.dragging = false
+		fcv.pan.dragging = false
 		fcv.clearHoverTarget()
+		fcv.clearPadClasses()
 		fcv.da.QueueDraw()
 	case 2, 3: // middle,right button
 		fcv.pan.dragging = false
@@ -293,6 +473,11 @@ func (fcv *FlowchartView) onReleaseEvent(area *gtk.DrawingArea, event *gdk.Event
 
 func (fcv *FlowchartView) onLeftFocus(area *gtk.DrawingArea, event *gdk.Event) {
 	fcv.lmc.dragging = false
+	fcv.marquee = false
+	fcv.reconnect = nil
+	fcv.clearPadClasses()
+	fcv.clearHover()
+	fcv.da.QueueDraw()
 }
 
 func (fcv *FlowchartView) onScrollEvent(area *gtk.DrawingArea, event *gdk.Event) {
@@ -314,11 +499,16 @@ func (fcv *FlowchartView) onScrollEvent(area *gtk.DrawingArea, event *gdk.Event)
 		amt *= -1
 	}
 
+	fcv.zoomAt(x, y, amt)
+}
+
+// zoomAt changes the zoom by the given amount, keeping the flowchart
+// position under the given point on the drawing area fixed.
+func (fcv *FlowchartView) zoomAt(x, y, amt float64) {
 	mouse := fcv.drawCoordsToFlow(x, y)
-	fcv.zoom += amt
-	if fcv.zoom <= 0.15 {
-		fcv.zoom = 0.15
-	}
+	fcv.transition = nil
+	fcv.zoom = fcv.clampZoom(fcv.zoom + amt)
+	fcv.model.setZoom(fcv.zoom)
 	after := fcv.drawCoordsToFlow(x, y)
 
 	fcv.offsetX -= (mouse.X - after.X) * fcv.zoom
@@ -337,6 +527,9 @@ func (fcv *FlowchartView) animationTick(widget *gtk.Widget, frameClock *gdk.Fram
 	if fcv.animStartTime == 0 {
 		fcv.animStartTime = fcv.animTime
 	}
+	if fcv.transition != nil {
+		fcv.stepTransition(fcv.animTime)
+	}
 	fcv.da.QueueDraw()
 	if !fcv.shouldAnimate() {
 		fcv.da.RemoveTickCallback(fcv.animHnd)
@@ -353,5 +546,5 @@ func (fcv *FlowchartView) shouldAnimate() bool {
 	if sp := fcv.draggingFromPad(); sp != nil {
 		return true // Animate selected pads.
 	}
-	return false
+	return fcv.transition != nil
 }
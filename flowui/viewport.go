@@ -0,0 +1,193 @@
+package flowui
+
+import (
+	"errors"
+	"math"
+
+	"github.com/twitchyliquid64/diagg/flow"
+	"github.com/twitchyliquid64/diagg/hit"
+)
+
+const (
+	// viewTransitionTime is the duration of animated viewport changes, in
+	// microseconds of frame clock time.
+	viewTransitionTime = 300 * 1000
+	// defaultFramePadding is the padding in pixels left around content
+	// framed by ZoomToSelection.
+	defaultFramePadding = 30
+
+	defaultMinZoom = 0.15
+	defaultMaxZoom = 5
+)
+
+// ErrInvalidZoomLimits is returned when zoom limits which cannot be applied
+// are set.
+var ErrInvalidZoomLimits = errors.New("minimum zoom must be positive, and maximum zoom must not be negative")
+
+// viewTransition describes an animated change of the viewport, between
+// two flowchart positions to center the view on, and two zoom levels.
+type viewTransition struct {
+	// start is the frame time the transition started, or zero if it has
+	// not yet started.
+	start                  int64
+	fromX, fromY, fromZoom float64
+	toX, toY, toZoom       float64
+}
+
+// easeInOut maps linear progress between 0 and 1 to eased progress.
+func easeInOut(t float64) float64 {
+	if t < 0.5 {
+		return 4 * t * t * t
+	}
+	return 1 - math.Pow(-2*t+2, 3)/2
+}
+
+// clampZoom limits the zoom level to the configured range.
+func (fcv *FlowchartView) clampZoom(zoom float64) float64 {
+	if fcv.maxZoom > 0 && zoom > fcv.maxZoom {
+		zoom = fcv.maxZoom
+	}
+	if zoom < fcv.minZoom {
+		zoom = fcv.minZoom
+	}
+	return zoom
+}
+
+// centerOn positions the view so the given flowchart position is in the
+// center of the drawing area.
+func (fcv *FlowchartView) centerOn(x, y, zoom float64) {
+	fcv.zoom = zoom
+	fcv.model.setZoom(zoom)
+	fcv.offsetX = float64(fcv.width)/2 - x*zoom
+	fcv.offsetY = float64(fcv.height)/2 - y*zoom
+}
+
+// animateTo starts an animated transition to center the view on the given
+// flowchart position, at the given zoom level.
+func (fcv *FlowchartView) animateTo(x, y, zoom float64) {
+	zoom = fcv.clampZoom(zoom)
+	center := fcv.drawCoordsToFlow(float64(fcv.width)/2, float64(fcv.height)/2)
+	fcv.transition = &viewTransition{
+		fromX:    center.X,
+		fromY:    center.Y,
+		fromZoom: fcv.zoom,
+		toX:      x,
+		toY:      y,
+		toZoom:   zoom,
+	}
+	fcv.ensureAnimating()
+}
+
+// stepTransition advances the viewport transition to the given frame time.
+func (fcv *FlowchartView) stepTransition(frameTime int64) {
+	t := fcv.transition
+	if t.start == 0 {
+		t.start = frameTime
+	}
+	progress := math.Min(1, float64(frameTime-t.start)/viewTransitionTime)
+	e := easeInOut(progress)
+
+	// The zoom is interpolated geometrically, so zooming in and out by the
+	// same factor appear to happen at the same speed.
+	zoom := t.fromZoom * math.Pow(t.toZoom/t.fromZoom, e)
+	fcv.centerOn(t.fromX+(t.toX-t.fromX)*e, t.fromY+(t.toY-t.fromY)*e, zoom)
+	if progress >= 1 {
+		fcv.transition = nil
+	}
+}
+
+// frame animates the view to show the given area of the flowchart, leaving
+// the given padding in pixels around it.
+func (fcv *FlowchartView) frame(min, max [2]float64, padding float64) {
+	var (
+		w, h   = max[0] - min[0], max[1] - min[1]
+		availW = float64(fcv.width) - 2*padding
+		availH = float64(fcv.height) - 2*padding
+		zoom   = fcv.zoom
+	)
+	if w > 0 && h > 0 && availW > 0 && availH > 0 {
+		zoom = math.Min(availW/w, availH/h)
+	}
+	fcv.animateTo((min[0]+max[0])/2, (min[1]+max[1])/2, zoom)
+}
+
+// ZoomToFit animates the view to show the whole flowchart, leaving the
+// given padding in pixels around it.
+func (fcv *FlowchartView) ZoomToFit(padding float64) {
+	min, max := fcv.model.l.Bounds()
+	fcv.frame(min, max, padding)
+}
+
+// ZoomToSelection animates the view to show all selected elements.
+func (fcv *FlowchartView) ZoomToSelection() {
+	var (
+		nodes      []flow.Node
+		haveBounds bool
+		min, max   [2]float64
+	)
+	include := func(x, y float64) {
+		if !haveBounds {
+			min, max, haveBounds = [2]float64{x, y}, [2]float64{x, y}, true
+			return
+		}
+		min[0], min[1] = math.Min(min[0], x), math.Min(min[1], y)
+		max[0], max[1] = math.Max(max[0], x), math.Max(max[1], y)
+	}
+
+	for _, s := range fcv.selection {
+		switch t := s.(type) {
+		case *rectNode:
+			nodes = append(nodes, t.Node())
+		case *circPad:
+			x, y := t.Pos()
+			include(x, y)
+		case *lineEdge:
+			for _, p := range t.points() {
+				include(p.X, p.Y)
+			}
+		}
+	}
+	if len(nodes) > 0 {
+		nMin, nMax := fcv.model.l.BoundsOf(nodes...)
+		include(nMin[0], nMin[1])
+		include(nMax[0], nMax[1])
+	}
+	if haveBounds {
+		fcv.frame(min, max, defaultFramePadding)
+	}
+}
+
+// FocusNode selects the given node, and animates the view to center on it.
+func (fcv *FlowchartView) FocusNode(n flow.Node) {
+	rn, ok := fcv.model.nodeState[n.NodeID()].(*rectNode)
+	if !ok {
+		return
+	}
+	fcv.lmc.target = rn
+	fcv.setSelection([]hit.TestableObj{rn})
+	fcv.selectionChanged()
+
+	x, y := rn.Pos()
+	fcv.animateTo(x, y, fcv.zoom)
+}
+
+// SetZoomLimits sets the minimum and maximum zoom level. A maximum of zero
+// places no upper limit on the zoom. If the minimum is greater than a
+// non-zero maximum, the two are swapped. ErrInvalidZoomLimits is returned,
+// and the limits are left unchanged, if the minimum is not a positive finite
+// number or the maximum is negative.
+func (fcv *FlowchartView) SetZoomLimits(min, max float64) error {
+	if !(min > 0) || math.IsInf(min, 1) || !(max >= 0) {
+		return ErrInvalidZoomLimits
+	}
+	if max > 0 && min > max {
+		min, max = max, min
+	}
+	fcv.minZoom, fcv.maxZoom = min, max
+	if zoom := fcv.clampZoom(fcv.zoom); zoom != fcv.zoom {
+		center := fcv.drawCoordsToFlow(float64(fcv.width)/2, float64(fcv.height)/2)
+		fcv.centerOn(center.X, center.Y, zoom)
+		fcv.da.QueueDraw()
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package hit
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// benchObjs returns n randomly positioned node-sized objects, spread over
+// an area of the given size.
+func benchObjs(n int, size float64) []dummyObj {
+	r := rand.New(rand.NewSource(1))
+	out := make([]dummyObj, n)
+	for i := range out {
+		x, y := r.Float64()*size, r.Float64()*size
+		out[i] = dummyObj{Point{x, y}, Point{x + 200, y + 120}}
+	}
+	return out
+}
+
+func benchmarkBuild(b *testing.B, mk func(min, max Point) hitTester, n int) {
+	objs := benchObjs(n, 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a := mk(Point{}, Point{10200, 10120})
+		for _, o := range objs {
+			a.Add(o.min, o.max, o)
+		}
+	}
+}
+
+func benchmarkTest(b *testing.B, mk func(min, max Point) hitTester, n int, boundsSize float64) {
+	objs := benchObjs(n, 10000)
+	a := mk(Point{}, Point{boundsSize, boundsSize})
+	for _, o := range objs {
+		a.Add(o.min, o.max, o)
+	}
+	r := rand.New(rand.NewSource(2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Test(Point{r.Float64() * 10000, r.Float64() * 10000})
+	}
+}
+
+func newArea(min, max Point) hitTester       { return NewArea(min, max) }
+func newGridTester(min, max Point) hitTester { return newGrid(min, max) }
+
+func BenchmarkBuildArea100(b *testing.B)  { benchmarkBuild(b, newArea, 100) }
+func BenchmarkBuildGrid100(b *testing.B)  { benchmarkBuild(b, newGridTester, 100) }
+func BenchmarkBuildArea2000(b *testing.B) { benchmarkBuild(b, newArea, 2000) }
+func BenchmarkBuildGrid2000(b *testing.B) { benchmarkBuild(b, newGridTester, 2000) }
+
+func BenchmarkTestArea100(b *testing.B)  { benchmarkTest(b, newArea, 100, 10200) }
+func BenchmarkTestGrid100(b *testing.B)  { benchmarkTest(b, newGridTester, 100, 10200) }
+func BenchmarkTestArea2000(b *testing.B) { benchmarkTest(b, newArea, 2000, 10200) }
+func BenchmarkTestGrid2000(b *testing.B) { benchmarkTest(b, newGridTester, 2000, 10200) }
+
+// The OutOfBounds benchmarks model objects having been dragged far outside
+// the bounds the index was constructed with.
+func BenchmarkTestAreaOutOfBounds(b *testing.B) { benchmarkTest(b, newArea, 2000, 500) }
+func BenchmarkTestGridOutOfBounds(b *testing.B) { benchmarkTest(b, newGridTester, 2000, 500) }
+
+// The Move benchmarks compare moving a single object during a drag: the
+// grid must remove the object from every bucket and add it again, whereas
+// the area can usually update the object in place.
+func benchmarkMove(b *testing.B, a hitTester, move func(o dummyObj, min, max Point)) {
+	objs := benchObjs(2000, 10000)
+	for _, o := range objs {
+		a.Add(o.min, o.max, o)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		o := objs[i%len(objs)]
+		d := float64(i % 16)
+		move(o, Point{o.min.X + d, o.min.Y + d}, Point{o.max.X + d, o.max.Y + d})
+	}
+}
+
+func BenchmarkMoveArea2000(b *testing.B) {
+	a := NewArea(Point{}, Point{10200, 10120})
+	benchmarkMove(b, a, func(o dummyObj, min, max Point) { a.Update(o, min, max) })
+}
+
+func BenchmarkMoveGrid2000(b *testing.B) {
+	a := newGrid(Point{}, Point{10200, 10120})
+	benchmarkMove(b, a, func(o dummyObj, min, max Point) {
+		a.Delete(o)
+		a.Add(min, max, o)
+	})
+}
@@ -0,0 +1,377 @@
+package hit
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	numX = 16
+	numY = 8
+)
+
+type object struct {
+	min, max Point
+	obj      TestableObj
+}
+
+type bucket struct {
+	objs []object
+}
+
+func (b *bucket) add(min, max Point, obj TestableObj) {
+	if b.objs == nil {
+		b.objs = make([]object, 0, 6)
+	}
+	b.objs = append(b.objs, object{min, max, obj})
+}
+
+func (b *bucket) test(p Point) TestableObj {
+	for i := len(b.objs) - 1; i >= 0; i-- {
+		o := b.objs[i]
+		if o.min.X <= p.X && o.min.Y <= p.Y && o.max.X >= p.X && o.max.Y >= p.Y {
+			if o.obj.HitTest(p) {
+				return o.obj
+			}
+		}
+	}
+	return nil
+}
+
+// grid implements hit testing by mapping objects into a fixed number of
+// buckets, spread evenly over bounds given at construction. Objects outside
+// those bounds are clamped into the buckets at the edges. It is the index
+// Area used before it was backed by a quadtree, and is kept as a baseline
+// for the benchmarks.
+type grid struct {
+	min, max         Point
+	xStride, yStride float64
+
+	xLen, yLen int
+	// buckets maps an object into X/Y buckets.
+	buckets [][]bucket
+}
+
+// Delete removes an object from the grid.
+func (a *grid) Delete(obj TestableObj) {
+	for x := range a.buckets {
+		for y := range a.buckets[x] {
+			b := &a.buckets[x][y]
+			idx := -1
+			for i := range b.objs {
+				if b.objs[i].obj == obj {
+					idx = i
+					break
+				}
+			}
+			if idx >= 0 {
+				b.objs = append(b.objs[:idx], b.objs[idx+1:]...)
+			}
+		}
+	}
+}
+
+// Add inserts the given object into the grid.
+func (a *grid) Add(min, max Point, obj TestableObj) {
+	minX, minY := a.mapToBucket(min)
+	maxX, maxY := a.mapToBucket(max)
+	xStride, yStride := maxX-minX, maxY-minY
+
+	switch {
+	case xStride > 0 && yStride > 0: // Covers buckets in both X and Y dimensions.
+		for xStride >= 0 {
+			for y := yStride; y >= 0; y-- {
+				a.buckets[minX+xStride][minY+y].add(min, max, obj)
+			}
+			xStride--
+		}
+
+	case xStride > 0: // Covers buckets only in X dimension.
+		for xStride >= 0 {
+			a.buckets[minX+xStride][minY].add(min, max, obj)
+			xStride--
+		}
+
+	case yStride > 0: // Covers buckets only in Y dimension.
+		for yStride >= 0 {
+			a.buckets[minX][minY+yStride].add(min, max, obj)
+			yStride--
+		}
+
+	default:
+		a.buckets[minX][minY].add(min, max, obj)
+	}
+}
+
+// Test computes the topmost object which intersects the given point.
+func (a *grid) Test(p Point) TestableObj {
+	x, y := a.mapToBucket(p)
+	return a.buckets[x][y].test(p)
+}
+
+func (a *grid) mapToBucket(p Point) (xIdx, yIdx int) {
+	p = p.Sub(a.min)
+	x, y := int(p.X*float64(a.xLen)/a.xStride), int(p.Y*float64(a.yLen)/a.yStride)
+
+	// Clamp to bounds.
+	if x >= a.xLen {
+		x = a.xLen - 1
+	}
+	if y >= a.yLen {
+		y = a.yLen - 1
+	}
+	if x < 0 {
+		x = 0
+	}
+	if y < 0 {
+		y = 0
+	}
+	return x, y
+}
+
+// newGrid constructs a grid covering the given bounds.
+func newGrid(min, max Point) *grid {
+	a := &grid{
+		min:     min,
+		max:     max,
+		xStride: max.X - min.X,
+		yStride: max.Y - min.Y,
+		xLen:    numX,
+		yLen:    numY,
+		buckets: make([][]bucket, numX),
+	}
+	for i := range a.buckets {
+		a.buckets[i] = make([]bucket, numY)
+	}
+
+	a.buckets[0][0].objs = make([]object, 0, 4)
+	a.buckets[a.xLen-1][0].objs = make([]object, 0, 4)
+	a.buckets[0][a.yLen-1].objs = make([]object, 0, 4)
+	a.buckets[a.xLen-1][a.yLen-1].objs = make([]object, 0, 4)
+
+	return a
+}
+
+func TestNewGrid(t *testing.T) {
+	min, max := Point{X: -20, Y: -15}, Point{X: 45, Y: 55}
+	a := newGrid(min, max)
+
+	a.buckets = nil // Too much
+	if diff := cmp.Diff(a, &grid{
+		min:     min,
+		max:     max,
+		xStride: 65,
+		yStride: 70,
+		xLen:    numX,
+		yLen:    numY,
+	}, cmp.AllowUnexported(grid{})); diff != "" {
+		t.Errorf("unexpected area (-got, +want): \n%s", diff)
+	}
+}
+
+func TestMapToBucket(t *testing.T) {
+	tcs := []struct {
+		name         string
+		min, max     Point
+		tp           Point
+		wantX, wantY int
+	}{
+		{
+			name:  "edge left",
+			min:   Point{0, 0},
+			max:   Point{100, 100},
+			tp:    Point{0, 50},
+			wantX: 0,
+			wantY: numY / 2,
+		},
+		{
+			name:  "edge right",
+			min:   Point{0, 0},
+			max:   Point{100, 100},
+			tp:    Point{100, 50},
+			wantX: numX - 1,
+			wantY: numY / 2,
+		},
+		{
+			name:  "center",
+			min:   Point{0, 0},
+			max:   Point{100, 100},
+			tp:    Point{50, 50},
+			wantX: numX / 2,
+			wantY: numY / 2,
+		},
+		{
+			name:  "edge top",
+			min:   Point{0, 0},
+			max:   Point{100, 100},
+			tp:    Point{50, 0},
+			wantX: numX / 2,
+			wantY: 0,
+		},
+		{
+			name:  "edge bottom",
+			min:   Point{0, 0},
+			max:   Point{100, 100},
+			tp:    Point{50, 100},
+			wantX: numX / 2,
+			wantY: numY - 1,
+		},
+		{
+			name:  "low oob",
+			min:   Point{0, 0},
+			max:   Point{100, 100},
+			tp:    Point{-50, -50},
+			wantX: 0,
+			wantY: 0,
+		},
+		{
+			name:  "high oob",
+			min:   Point{0, 0},
+			max:   Point{100, 100},
+			tp:    Point{500, 500},
+			wantX: numX - 1,
+			wantY: numY - 1,
+		},
+		{
+			name:  "one oob",
+			min:   Point{0, 0},
+			max:   Point{100, 100},
+			tp:    Point{50, 500},
+			wantX: numX / 2,
+			wantY: numY - 1,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newGrid(tc.min, tc.max)
+			if gotX, gotY := a.mapToBucket(tc.tp); gotX != tc.wantX || gotY != tc.wantY {
+				t.Errorf("mapToBucket(%v) = (%d,%d), want (%d,%d)", tc.tp, gotX, gotY, tc.wantX, tc.wantY)
+			}
+		})
+	}
+}
+
+func TestGridAdd(t *testing.T) {
+	tcs := []struct {
+		name        string
+		min, max    Point
+		wantBuckets [][]int
+	}{
+		{
+			name:        "single point low",
+			min:         Point{0, 0},
+			max:         Point{0, 0},
+			wantBuckets: [][]int{[]int{0, 0}},
+		},
+		{
+			name:        "single point mid",
+			min:         Point{50, 50},
+			max:         Point{50, 50},
+			wantBuckets: [][]int{[]int{numX / 2, numY / 2}},
+		},
+		{
+			name:        "single point high",
+			min:         Point{100, 100},
+			max:         Point{100, 100},
+			wantBuckets: [][]int{[]int{numX - 1, numY - 1}},
+		},
+		{
+			name:        "single point oob",
+			min:         Point{10000, 10000},
+			max:         Point{10000, 10000},
+			wantBuckets: [][]int{[]int{numX - 1, numY - 1}},
+		},
+		{
+			name:        "line x",
+			min:         Point{0, 0},
+			max:         Point{8, 0},
+			wantBuckets: [][]int{{0, 0}, {1, 0}},
+		},
+		{
+			name:        "line y",
+			min:         Point{0, 0},
+			max:         Point{0, 18},
+			wantBuckets: [][]int{{0, 0}, {0, 1}},
+		},
+		{
+			name:        "line x long",
+			min:         Point{0, 0},
+			max:         Point{38, 0},
+			wantBuckets: [][]int{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {6, 0}},
+		},
+		{
+			name:        "line y long",
+			min:         Point{0, 0},
+			max:         Point{0, 55},
+			wantBuckets: [][]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {0, 4}},
+		},
+		{
+			name:        "block small",
+			min:         Point{0, 0},
+			max:         Point{10, 13},
+			wantBuckets: [][]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}},
+		},
+		{
+			name:        "block med x",
+			min:         Point{0, 0},
+			max:         Point{17, 13},
+			wantBuckets: [][]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}, {2, 0}, {2, 1}},
+		},
+		{
+			name:        "block med y",
+			min:         Point{0, 0},
+			max:         Point{10, 25},
+			wantBuckets: [][]int{{0, 0}, {0, 1}, {0, 2}, {1, 0}, {1, 1}, {1, 2}},
+		},
+		{
+			name:        "block tiny ",
+			min:         Point{8, 13},
+			max:         Point{11, 14},
+			wantBuckets: [][]int{{1, 1}},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newGrid(Point{}, Point{100, 100})
+			a.Add(tc.min, tc.max, nil)
+
+			hadBuckets := make([][]int, 0)
+			for x := 0; x < a.xLen; x++ {
+				for y := 0; y < a.yLen; y++ {
+					if len(a.buckets[x][y].objs) > 0 {
+						hadBuckets = append(hadBuckets, []int{x, y})
+					}
+				}
+			}
+
+			if diff := cmp.Diff(tc.wantBuckets, hadBuckets); diff != "" {
+				t.Errorf("Different buckets populated than expected (+got, -want): %s\n", diff)
+			}
+		})
+	}
+}
+
+func TestGridHitTest(t *testing.T) {
+	testHitTest(t, newGrid(Point{}, Point{400, 400}))
+}
+
+func TestGridDelete(t *testing.T) {
+	a := newGrid(Point{}, Point{100, 100})
+	d := dummyObj{Point{10, 10}, Point{60, 60}}
+	a.Add(d.min, d.max, d)
+	a.Delete(d)
+
+	for x := range a.buckets {
+		for y := range a.buckets[x] {
+			if n := len(a.buckets[x][y].objs); n != 0 {
+				t.Errorf("bucket (%d,%d) has %d objects after Delete", x, y, n)
+			}
+		}
+	}
+	if got := a.Test(Point{20, 20}); got != nil {
+		t.Errorf("Test(20,20) = %v, want nil", got)
+	}
+}
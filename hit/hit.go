@@ -1,8 +1,17 @@
+// Package hit implements spatial indexing for hit testing.
 package hit
 
+import "math"
+
 const (
-	numX = 16
-	numY = 8
+	// quadCapacity is the number of objects a quad holds before it is split.
+	quadCapacity = 8
+	// minQuadSize is the smallest width or height a quad can be split to.
+	minQuadSize = 4
+	// maxGrowth bounds the number of times the root of an Area can double
+	// in size to accommodate a single object. Objects which would not fit
+	// are kept in the overflow list of the area, without growing the root.
+	maxGrowth = 64
 )
 
 type Point struct {
@@ -17,138 +26,392 @@ type TestableObj interface {
 	HitTest(Point) bool
 }
 
-type object struct {
+// entry describes an object stored in an Area.
+type entry struct {
 	min, max Point
 	obj      TestableObj
+	// seq orders objects in z-order: objects with a higher seq are on top.
+	seq  uint64
+	quad *quad
+}
+
+func (e *entry) contains(p Point) bool {
+	return e.min.X <= p.X && e.min.Y <= p.Y && e.max.X >= p.X && e.max.Y >= p.Y
+}
+
+// quad is a node of the quadtree backing an Area. Objects are stored in the
+// smallest quad which fully contains them.
+type quad struct {
+	min, max Point
+	parent   *quad
+	children *[4]quad
+	entries  []*entry
+	// count is the number of entries in this quad and all its descendants.
+	count int
 }
 
-type bucket struct {
-	objs []object
+func (q *quad) encloses(min, max Point) bool {
+	return q.min.X <= min.X && q.min.Y <= min.Y && q.max.X >= max.X && q.max.Y >= max.Y
 }
 
-func (b *bucket) add(min, max Point, obj TestableObj) {
-	if b.objs == nil {
-		b.objs = make([]object, 0, 6)
+func (q *quad) containsPoint(p Point) bool {
+	return q.min.X <= p.X && q.min.Y <= p.Y && q.max.X >= p.X && q.max.Y >= p.Y
+}
+
+func (q *quad) split() {
+	mid := Point{X: (q.min.X + q.max.X) / 2, Y: (q.min.Y + q.max.Y) / 2}
+	q.children = &[4]quad{
+		{min: q.min, max: mid, parent: q},
+		{min: Point{X: mid.X, Y: q.min.Y}, max: Point{X: q.max.X, Y: mid.Y}, parent: q},
+		{min: Point{X: q.min.X, Y: mid.Y}, max: Point{X: mid.X, Y: q.max.Y}, parent: q},
+		{min: mid, max: q.max, parent: q},
+	}
+
+	// Push down any entries which now fit entirely in a child.
+	kept := q.entries[:0]
+	for _, e := range q.entries {
+		if c := q.childEnclosing(e.min, e.max); c != nil {
+			c.entries = append(c.entries, e)
+			c.count++
+			e.quad = c
+		} else {
+			kept = append(kept, e)
+		}
 	}
-	b.objs = append(b.objs, object{min, max, obj})
+	q.entries = kept
 }
 
-func (b *bucket) test(p Point) TestableObj {
-	for i := len(b.objs) - 1; i >= 0; i-- {
-		o := b.objs[i]
-		if o.min.X <= p.X && o.min.Y <= p.Y && o.max.X >= p.X && o.max.Y >= p.Y {
-			if o.obj.HitTest(p) {
-				return o.obj
-			}
+func (q *quad) childEnclosing(min, max Point) *quad {
+	if q.children == nil {
+		return nil
+	}
+	for i := range q.children {
+		if q.children[i].encloses(min, max) {
+			return &q.children[i]
 		}
 	}
 	return nil
 }
 
-// Area encapsulates a hit testing region.
-type Area struct {
-	min, max         Point
-	xStride, yStride float64
+func (q *quad) insert(e *entry) {
+	for {
+		q.count++
+		if c := q.childEnclosing(e.min, e.max); c != nil {
+			q = c
+			continue
+		}
+		q.entries = append(q.entries, e)
+		e.quad = q
 
-	xLen, yLen int
-	// buckets maps an object into X/Y buckets.
-	buckets [][]bucket
+		if q.children == nil && len(q.entries) > quadCapacity &&
+			q.max.X-q.min.X > minQuadSize && q.max.Y-q.min.Y > minQuadSize {
+			q.split()
+		}
+		return
+	}
 }
 
-// Delete removes an object from the hit testing arena.
-func (a *Area) Delete(obj TestableObj) {
-	for _, x := range a.buckets {
-		for _, b := range x {
-			idx := -1
-			for i := range b.objs {
-				if b.objs[i].obj == obj {
-					idx = i
-					break
-				}
-			}
-			if idx >= 0 {
-				b.objs = append(b.objs[:idx], b.objs[idx+1:]...)
-			}
+// collect appends all entries in the quad and its descendants to out.
+func (q *quad) collect(out []*entry) []*entry {
+	out = append(out, q.entries...)
+	if q.children != nil {
+		for i := range q.children {
+			out = q.children[i].collect(out)
 		}
 	}
+	return out
 }
 
-// Add inserts the given object into the hit testing area.
-func (a *Area) Add(min, max Point, obj TestableObj) {
-	minX, minY := a.mapToBucket(min)
-	maxX, maxY := a.mapToBucket(max)
-	xStride, yStride := maxX-minX, maxY-minY
-
-	switch {
-	case xStride > 0 && yStride > 0: // Covers buckets in both X and Y dimensions.
-		for xStride >= 0 {
-			for y := yStride; y >= 0; y-- {
-				a.buckets[minX+xStride][minY+y].add(min, max, obj)
-			}
-			xStride--
+// visitPoint invokes fn for every entry with bounds containing the point.
+func (q *quad) visitPoint(p Point, fn func(*entry)) {
+	if q.count == 0 || !q.containsPoint(p) {
+		return
+	}
+	for _, e := range q.entries {
+		if e.contains(p) {
+			fn(e)
+		}
+	}
+	if q.children != nil {
+		for i := range q.children {
+			q.children[i].visitPoint(p, fn)
 		}
+	}
+}
 
-	case xStride > 0: // Covers buckets only in X dimension.
-		for xStride >= 0 {
-			a.buckets[minX+xStride][minY].add(min, max, obj)
-			xStride--
+// remove deletes an entry from the quad, merging the children of the quad
+// or its parents back together once they hold few enough objects.
+func (q *quad) remove(e *entry) {
+	for i, qe := range q.entries {
+		if qe == e {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			break
 		}
+	}
+	e.quad = nil
 
-	case yStride > 0: // Covers buckets only in Y dimension.
-		for yStride >= 0 {
-			a.buckets[minX][minY+yStride].add(min, max, obj)
-			yStride--
+	var collapse *quad
+	for p := q; p != nil; p = p.parent {
+		p.count--
+		if p.children != nil && p.count <= quadCapacity/2 {
+			collapse = p
+		}
+	}
+	if collapse != nil {
+		collapse.entries = collapse.collect(nil)
+		collapse.children = nil
+		for _, ce := range collapse.entries {
+			ce.quad = collapse
 		}
+	}
+}
 
-	default:
-		a.buckets[minX][minY].add(min, max, obj)
+// Area encapsulates a hit testing region. Objects are indexed in a quadtree,
+// which grows to accommodate objects outside its current bounds and merges
+// sparse regions back together as objects are removed.
+type Area struct {
+	root *quad
+	// min and max are the initial bounds of the root, which it shrinks back
+	// to as objects outside them are removed.
+	min, max Point
+	// overflow holds objects the tree could not grow to enclose, such as
+	// those with infinite or NaN bounds. It covers the whole plane and is
+	// never split, so its objects are searched linearly.
+	overflow *quad
+	objs     map[TestableObj]*entry
+	nextSeq  uint64
+}
+
+// growStep returns the bounds of the root after doubling it once towards
+// the given bounds, and the index of the child the old root becomes.
+func growStep(qMin, qMax, min Point) (Point, Point, int) {
+	var (
+		w, h = qMax.X - qMin.X, qMax.Y - qMin.Y
+		idx  int
+	)
+	if min.X < qMin.X {
+		qMin.X -= w
+		idx |= 1
+	} else {
+		qMax.X += w
 	}
+	if min.Y < qMin.Y {
+		qMin.Y -= h
+		idx |= 2
+	} else {
+		qMax.Y += h
+	}
+	return qMin, qMax, idx
 }
 
-// Test computes the topmost object which intersects the given point.
-func (a *Area) Test(p Point) TestableObj {
-	x, y := a.mapToBucket(p)
-	return a.buckets[x][y].test(p)
+func finite(p Point) bool {
+	return !math.IsNaN(p.X) && !math.IsNaN(p.Y) && !math.IsInf(p.X, 0) && !math.IsInf(p.Y, 0)
+}
+
+// canGrow returns true if the root can be grown to enclose the given bounds
+// within maxGrowth steps.
+func (a *Area) canGrow(min, max Point) bool {
+	if !finite(min) || !finite(max) {
+		return false
+	}
+	q := quad{min: a.root.min, max: a.root.max}
+	for i := 0; i < maxGrowth && !q.encloses(min, max); i++ {
+		q.min, q.max, _ = growStep(q.min, q.max, min)
+	}
+	return q.encloses(min, max)
+}
+
+// grow expands the tree until the root encloses the given bounds. Each step
+// doubles the size of the root, towards the bounds. It returns false,
+// leaving the root unchanged, if the root cannot be grown large enough.
+func (a *Area) grow(min, max Point) bool {
+	if !a.canGrow(min, max) {
+		return false
+	}
+	for !a.root.encloses(min, max) {
+		var (
+			old     = a.root
+			newRoot = &quad{count: old.count}
+			idx     int
+		)
+		newRoot.min, newRoot.max, idx = growStep(old.min, old.max, min)
+
+		newRoot.split()
+		newRoot.children[idx] = *old
+		moved := &newRoot.children[idx]
+		moved.parent = newRoot
+		moved.reparent()
+		a.root = newRoot
+	}
+	return true
 }
 
-func (a *Area) mapToBucket(p Point) (xIdx, yIdx int) {
-	p = p.Sub(a.min)
-	x, y := int(p.X*float64(a.xLen)/a.xStride), int(p.Y*float64(a.yLen)/a.yStride)
+// shrink undoes growth of the root which is no longer needed, once the
+// objects outside the initial bounds have been removed. The root is never
+// shrunk smaller than the initial bounds.
+func (a *Area) shrink() {
+	for a.root.children != nil && len(a.root.entries) == 0 {
+		var only *quad
+		for i := range a.root.children {
+			if c := &a.root.children[i]; c.count > 0 {
+				if only != nil {
+					return
+				}
+				only = c
+			}
+		}
+		if only == nil || !only.encloses(a.min, a.max) {
+			break
+		}
+		newRoot := &quad{}
+		*newRoot = *only
+		newRoot.parent = nil
+		newRoot.reparent()
+		a.root = newRoot
+	}
 
-	// Clamp to bounds.
-	if x >= a.xLen {
-		x = a.xLen - 1
+	// A root holding few objects is cheap to rebuild from the initial
+	// bounds, which shrinks it if it has been collapsed after growing.
+	if a.root.count > quadCapacity || (a.root.min == a.min && a.root.max == a.max) {
+		return
 	}
-	if y >= a.yLen {
-		y = a.yLen - 1
+	entries := a.root.collect(nil)
+	a.root = &quad{min: a.min, max: a.max}
+	for _, e := range entries {
+		a.grow(e.min, e.max)
+		a.root.insert(e)
 	}
-	if x < 0 {
-		x = 0
+}
+
+// place inserts an entry into the tree, or the overflow list if the tree
+// cannot enclose it.
+func (a *Area) place(e *entry) {
+	if a.grow(e.min, e.max) {
+		a.root.insert(e)
+		return
 	}
-	if y < 0 {
-		y = 0
+	a.overflow.entries = append(a.overflow.entries, e)
+	a.overflow.count++
+	e.quad = a.overflow
+}
+
+// visitPoint invokes fn for every entry with bounds containing the point.
+func (a *Area) visitPoint(p Point, fn func(*entry)) {
+	a.root.visitPoint(p, fn)
+	a.overflow.visitPoint(p, fn)
+}
+
+// visitRect invokes fn for every entry with bounds overlapping the rectangle.
+func (a *Area) visitRect(min, max Point, fn func(*entry)) {
+	a.root.visitRect(min, max, fn)
+	a.overflow.visitRect(min, max, fn)
+}
+
+// reparent fixes up pointers after a quad has been moved in memory.
+func (q *quad) reparent() {
+	for _, e := range q.entries {
+		e.quad = q
+	}
+	if q.children != nil {
+		for i := range q.children {
+			q.children[i].parent = q
+		}
 	}
-	return x, y
 }
 
-func NewArea(min, max Point) *Area {
-	a := &Area{
-		min:     min,
-		max:     max,
-		xStride: max.X - min.X,
-		yStride: max.Y - min.Y,
-		xLen:    numX,
-		yLen:    numY,
-		buckets: make([][]bucket, numX),
+// Delete removes an object from the hit testing arena.
+func (a *Area) Delete(obj TestableObj) {
+	if e, ok := a.objs[obj]; ok {
+		e.quad.remove(e)
+		delete(a.objs, obj)
+		a.shrink()
+	}
+}
+
+// Add inserts the given object into the hit testing area, on top of all
+// existing objects. If the object is already present, it is moved to the
+// new bounds and raised to the top.
+func (a *Area) Add(min, max Point, obj TestableObj) {
+	a.Delete(obj)
+	e := &entry{min: min, max: max, obj: obj, seq: a.nextSeq}
+	a.nextSeq++
+
+	a.place(e)
+	a.objs[obj] = e
+}
+
+// Update changes the bounds of an object already in the area, without
+// changing its position in the z-order. If the object is not present, it
+// is added on top of all existing objects.
+func (a *Area) Update(obj TestableObj, min, max Point) {
+	e, ok := a.objs[obj]
+	if !ok {
+		a.Add(min, max, obj)
+		return
 	}
-	for i := range a.buckets {
-		a.buckets[i] = make([]bucket, numY)
+
+	// If the object still belongs in the same quad, there is no need to
+	// touch the tree. Objects in the overflow list are always re-placed, in
+	// case they now fit in the tree.
+	if e.quad != a.overflow && e.quad.encloses(min, max) && e.quad.childEnclosing(min, max) == nil {
+		e.min, e.max = min, max
+		return
 	}
 
-	a.buckets[0][0].objs = make([]object, 0, 4)
-	a.buckets[a.xLen-1][0].objs = make([]object, 0, 4)
-	a.buckets[0][a.yLen-1].objs = make([]object, 0, 4)
-	a.buckets[a.xLen-1][a.yLen-1].objs = make([]object, 0, 4)
+	e.quad.remove(e)
+	a.shrink()
+	e.min, e.max = min, max
+	a.place(e)
+}
+
+// Len returns the number of objects in the area.
+func (a *Area) Len() int {
+	return len(a.objs)
+}
 
-	return a
+// Test computes the topmost object which intersects the given point.
+func (a *Area) Test(p Point) TestableObj {
+	var best *entry
+	a.visitPoint(p, func(e *entry) {
+		if (best == nil || e.seq > best.seq) && e.obj.HitTest(p) {
+			best = e
+		}
+	})
+	if best == nil {
+		return nil
+	}
+	return best.obj
+}
+
+// TestAll returns every object which intersects the given point, ordered
+// from topmost to bottommost.
+func (a *Area) TestAll(p Point) []TestableObj {
+	var hits []*entry
+	a.visitPoint(p, func(e *entry) {
+		if e.obj.HitTest(p) {
+			hits = append(hits, e)
+		}
+	})
+	return byZ(hits)
+}
+
+// NewArea constructs a hit testing area. The bounds are used as the initial
+// extent of the area, which grows as objects are added outside of it.
+func NewArea(min, max Point) *Area {
+	if max.X-min.X < minQuadSize {
+		max.X = min.X + minQuadSize
+	}
+	if max.Y-min.Y < minQuadSize {
+		max.Y = min.Y + minQuadSize
+	}
+	return &Area{
+		root: &quad{min: min, max: max},
+		min:  min,
+		max:  max,
+		overflow: &quad{
+			min: Point{X: math.Inf(-1), Y: math.Inf(-1)},
+			max: Point{X: math.Inf(1), Y: math.Inf(1)},
+		},
+		objs: make(map[TestableObj]*entry, 64),
+	}
 }
@@ -1,207 +1,73 @@
 package hit
 
 import (
+	"math"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 )
 
 func TestNewArea(t *testing.T) {
-	min, max := Point{X: -20, Y: -15}, Point{X: 45, Y: 55}
-	a := NewArea(min, max)
-
-	a.buckets = nil // Too much
-	if diff := cmp.Diff(a, &Area{
-		min:     min,
-		max:     max,
-		xStride: 65,
-		yStride: 70,
-		xLen:    numX,
-		yLen:    numY,
-	}, cmp.AllowUnexported(Area{})); diff != "" {
-		t.Errorf("unexpected area (-got, +want): \n%s", diff)
-	}
-}
-
-func TestMapToBucket(t *testing.T) {
 	tcs := []struct {
-		name         string
-		min, max     Point
-		tp           Point
-		wantX, wantY int
+		name             string
+		min, max         Point
+		wantMin, wantMax Point
 	}{
-		{
-			name:  "edge left",
-			min:   Point{0, 0},
-			max:   Point{100, 100},
-			tp:    Point{0, 50},
-			wantX: 0,
-			wantY: numY / 2,
-		},
-		{
-			name:  "edge right",
-			min:   Point{0, 0},
-			max:   Point{100, 100},
-			tp:    Point{100, 50},
-			wantX: numX - 1,
-			wantY: numY / 2,
-		},
-		{
-			name:  "center",
-			min:   Point{0, 0},
-			max:   Point{100, 100},
-			tp:    Point{50, 50},
-			wantX: numX / 2,
-			wantY: numY / 2,
-		},
-		{
-			name:  "edge top",
-			min:   Point{0, 0},
-			max:   Point{100, 100},
-			tp:    Point{50, 0},
-			wantX: numX / 2,
-			wantY: 0,
-		},
-		{
-			name:  "edge bottom",
-			min:   Point{0, 0},
-			max:   Point{100, 100},
-			tp:    Point{50, 100},
-			wantX: numX / 2,
-			wantY: numY - 1,
-		},
-		{
-			name:  "low oob",
-			min:   Point{0, 0},
-			max:   Point{100, 100},
-			tp:    Point{-50, -50},
-			wantX: 0,
-			wantY: 0,
-		},
-		{
-			name:  "high oob",
-			min:   Point{0, 0},
-			max:   Point{100, 100},
-			tp:    Point{500, 500},
-			wantX: numX - 1,
-			wantY: numY - 1,
-		},
-		{
-			name:  "one oob",
-			min:   Point{0, 0},
-			max:   Point{100, 100},
-			tp:    Point{50, 500},
-			wantX: numX / 2,
-			wantY: numY - 1,
-		},
+		{"bounds", Point{X: -20, Y: -15}, Point{X: 45, Y: 55}, Point{X: -20, Y: -15}, Point{X: 45, Y: 55}},
+		{"too small", Point{X: 10, Y: 10}, Point{X: 11, Y: 10}, Point{X: 10, Y: 10}, Point{X: 14, Y: 14}},
 	}
-
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
 			a := NewArea(tc.min, tc.max)
-			if gotX, gotY := a.mapToBucket(tc.tp); gotX != tc.wantX || gotY != tc.wantY {
-				t.Errorf("mapToBucket(%v) = (%d,%d), want (%d,%d)", tc.tp, gotX, gotY, tc.wantX, tc.wantY)
+			if a.root.min != tc.wantMin || a.root.max != tc.wantMax {
+				t.Errorf("root bounds = (%v, %v), want (%v, %v)", a.root.min, a.root.max, tc.wantMin, tc.wantMax)
+			}
+			if a.Len() != 0 || a.root.children != nil {
+				t.Errorf("new area is not empty: Len() = %d, split = %v", a.Len(), a.root.children != nil)
 			}
 		})
 	}
 }
 
 func TestAreaAdd(t *testing.T) {
+	inf := math.Inf(1)
 	tcs := []struct {
-		name        string
-		min, max    Point
-		wantBuckets [][]int
+		name         string
+		min, max     Point
+		wantOverflow bool
 	}{
-		{
-			name:        "single point low",
-			min:         Point{0, 0},
-			max:         Point{0, 0},
-			wantBuckets: [][]int{[]int{0, 0}},
-		},
-		{
-			name:        "single point mid",
-			min:         Point{50, 50},
-			max:         Point{50, 50},
-			wantBuckets: [][]int{[]int{numX / 2, numY / 2}},
-		},
-		{
-			name:        "single point high",
-			min:         Point{100, 100},
-			max:         Point{100, 100},
-			wantBuckets: [][]int{[]int{numX - 1, numY - 1}},
-		},
-		{
-			name:        "single point oob",
-			min:         Point{10000, 10000},
-			max:         Point{10000, 10000},
-			wantBuckets: [][]int{[]int{numX - 1, numY - 1}},
-		},
-		{
-			name:        "line x",
-			min:         Point{0, 0},
-			max:         Point{8, 0},
-			wantBuckets: [][]int{{0, 0}, {1, 0}},
-		},
-		{
-			name:        "line y",
-			min:         Point{0, 0},
-			max:         Point{0, 18},
-			wantBuckets: [][]int{{0, 0}, {0, 1}},
-		},
-		{
-			name:        "line x long",
-			min:         Point{0, 0},
-			max:         Point{38, 0},
-			wantBuckets: [][]int{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {6, 0}},
-		},
-		{
-			name:        "line y long",
-			min:         Point{0, 0},
-			max:         Point{0, 55},
-			wantBuckets: [][]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {0, 4}},
-		},
-		{
-			name:        "block small",
-			min:         Point{0, 0},
-			max:         Point{10, 13},
-			wantBuckets: [][]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}},
-		},
-		{
-			name:        "block med x",
-			min:         Point{0, 0},
-			max:         Point{17, 13},
-			wantBuckets: [][]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}, {2, 0}, {2, 1}},
-		},
-		{
-			name:        "block med y",
-			min:         Point{0, 0},
-			max:         Point{10, 25},
-			wantBuckets: [][]int{{0, 0}, {0, 1}, {0, 2}, {1, 0}, {1, 1}, {1, 2}},
-		},
-		{
-			name:        "block tiny ",
-			min:         Point{8, 13},
-			max:         Point{11, 14},
-			wantBuckets: [][]int{{1, 1}},
-		},
+		{"single point", Point{50, 50}, Point{50, 50}, false},
+		{"block", Point{10, 10}, Point{30, 20}, false},
+		{"outside", Point{10000, -10000}, Point{10010, -9990}, false},
+		{"far outside", Point{1e300, 0}, Point{1e300, 10}, true},
+		{"infinite", Point{0, 0}, Point{inf, 10}, true},
+		{"NaN", Point{math.NaN(), 0}, Point{10, 10}, true},
 	}
-
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
 			a := NewArea(Point{}, Point{100, 100})
-			a.Add(tc.min, tc.max, nil)
-
-			hadBuckets := make([][]int, 0)
-			for x := 0; x < a.xLen; x++ {
-				for y := 0; y < a.yLen; y++ {
-					if len(a.buckets[x][y].objs) > 0 {
-						hadBuckets = append(hadBuckets, []int{x, y})
-					}
-				}
-			}
+			d := &movingObj{dummyObj{tc.min, tc.max}}
+			a.Add(tc.min, tc.max, d)
 
-			if diff := cmp.Diff(tc.wantBuckets, hadBuckets); diff != "" {
-				t.Errorf("Different buckets populated than expected (+got, -want): %s\n", diff)
+			e := a.objs[d]
+			if e == nil || e.quad == nil {
+				t.Fatal("object was not stored")
+			}
+			if gotOverflow := e.quad == a.overflow; gotOverflow != tc.wantOverflow {
+				t.Errorf("object in overflow = %v, want %v", gotOverflow, tc.wantOverflow)
+			}
+			if tc.wantOverflow && (a.root.min != (Point{}) || a.root.max != (Point{100, 100})) {
+				t.Errorf("root grew to (%v, %v) for an object in overflow", a.root.min, a.root.max)
+			}
+			if !tc.wantOverflow && !e.quad.encloses(tc.min, tc.max) {
+				t.Errorf("quad (%v, %v) does not enclose object", e.quad.min, e.quad.max)
+			}
+			if a.Len() != 1 {
+				t.Errorf("Len() = %d, want 1", a.Len())
+			}
+			a.Delete(d)
+			if a.Len() != 0 || a.root.count != 0 || a.overflow.count != 0 {
+				t.Errorf("after Delete: Len() = %d, root count = %d, overflow count = %d", a.Len(), a.root.count, a.overflow.count)
 			}
 		})
 	}
@@ -215,9 +81,19 @@ func (d dummyObj) HitTest(p Point) bool {
 	return p.X >= d.min.X && p.X <= d.max.X && p.Y >= d.min.Y && p.Y <= d.max.Y
 }
 
+// hitTester describes the methods common to Area and the grid used as a
+// baseline in benchmarks.
+type hitTester interface {
+	Add(min, max Point, obj TestableObj)
+	Delete(obj TestableObj)
+	Test(p Point) TestableObj
+}
+
 func TestAreaHitTest(t *testing.T) {
-	a := NewArea(Point{}, Point{400, 400})
+	testHitTest(t, NewArea(Point{}, Point{400, 400}))
+}
 
+func testHitTest(t *testing.T, a hitTester) {
 	d := dummyObj{Point{35, 55}, Point{121, 185}}
 	a.Add(d.min, d.max, d)
 
@@ -251,3 +127,424 @@ func TestAreaHitTest(t *testing.T) {
 		t.Errorf("Test(38,55) = %v, want %v", got, d)
 	}
 }
+
+func TestAreaGrows(t *testing.T) {
+	a := NewArea(Point{}, Point{100, 100})
+
+	objs := []dummyObj{
+		{Point{10, 10}, Point{20, 20}},
+		{Point{-500, -500}, Point{-400, -450}},
+		{Point{5000, 40}, Point{5100, 60}},
+		{Point{-20, 3000}, Point{20, 3020}},
+	}
+	for _, o := range objs {
+		a.Add(o.min, o.max, o)
+	}
+	if !a.root.encloses(Point{-500, -500}, Point{5100, 3020}) {
+		t.Errorf("root bounds (%v, %v) do not enclose all objects", a.root.min, a.root.max)
+	}
+
+	for _, o := range objs {
+		center := Point{(o.min.X + o.max.X) / 2, (o.min.Y + o.max.Y) / 2}
+		if got := a.Test(center); got != o {
+			t.Errorf("Test(%v) = %v, want %v", center, got, o)
+		}
+	}
+	if got := a.Test(Point{1000, 1000}); got != nil {
+		t.Errorf("Test(1000,1000) = %v, want nil", got)
+	}
+}
+
+func TestAreaShrinks(t *testing.T) {
+	a := NewArea(Point{}, Point{100, 100})
+	near := dummyObj{Point{10, 10}, Point{20, 20}}
+	far := dummyObj{Point{1e6, -1e6}, Point{1e6 + 10, -1e6 + 10}}
+	a.Add(near.min, near.max, near)
+	a.Add(far.min, far.max, far)
+	if !a.root.encloses(far.min, far.max) {
+		t.Fatalf("root bounds (%v, %v) do not enclose far object", a.root.min, a.root.max)
+	}
+
+	a.Delete(far)
+	if a.root.min != (Point{}) || a.root.max != (Point{100, 100}) {
+		t.Errorf("root bounds = (%v, %v), want initial bounds", a.root.min, a.root.max)
+	}
+	if got := a.Test(Point{15, 15}); got != near {
+		t.Errorf("Test(15,15) = %v, want %v", got, near)
+	}
+}
+
+func TestAreaInfiniteDoesNotGrow(t *testing.T) {
+	a := NewArea(Point{}, Point{100, 100})
+	inf := dummyObj{Point{0, 0}, Point{math.Inf(1), 10}}
+	a.Add(inf.min, inf.max, inf)
+
+	if a.root.min != (Point{}) || a.root.max != (Point{100, 100}) {
+		t.Errorf("root bounds = (%v, %v), want initial bounds", a.root.min, a.root.max)
+	}
+	if got := a.Test(Point{1e9, 5}); got != inf {
+		t.Errorf("Test(1e9,5) = %v, want %v", got, inf)
+	}
+}
+
+func TestAreaOverflow(t *testing.T) {
+	a := NewArea(Point{}, Point{100, 100})
+	near := dummyObj{Point{10, 10}, Point{20, 20}}
+	far := &movingObj{dummyObj{Point{1e300, 1e300}, Point{1e300 + 1e290, 1e300 + 1e290}}}
+	wide := dummyObj{Point{math.Inf(-1), 40}, Point{math.Inf(1), 50}}
+	a.Add(near.min, near.max, near)
+	a.Add(far.min, far.max, far)
+	a.Add(wide.min, wide.max, wide)
+
+	if a.overflow.count != 2 {
+		t.Fatalf("overflow count = %d, want 2", a.overflow.count)
+	}
+	if got := a.Test(far.min); got != far {
+		t.Errorf("Test(%v) = %v, want %v", far.min, got, far)
+	}
+	if got := a.Test(Point{-1e9, 45}); got != wide {
+		t.Errorf("Test(-1e9,45) = %v, want %v", got, wide)
+	}
+	if diff := cmp.Diff([]TestableObj{wide, near}, a.QueryRect(Point{0, 0}, Point{100, 100}, Overlapping), cmp.AllowUnexported(dummyObj{})); diff != "" {
+		t.Errorf("QueryRect() differs (-want, +got): \n%s", diff)
+	}
+	if got := a.Nearest(Point{50, 30}, 1, math.Inf(1), nil); len(got) != 1 || got[0].Obj != wide {
+		t.Errorf("Nearest(50,30) = %v, want %v", got, wide)
+	}
+	if got := a.WithinRadius(Point{50, 30}, 15, nil); len(got) != 1 || got[0].Obj != wide {
+		t.Errorf("WithinRadius(50,30) = %v, want %v", got, wide)
+	}
+
+	// Moving the object back in range should return it to the tree.
+	far.min, far.max = Point{60, 60}, Point{70, 70}
+	a.Update(far, far.min, far.max)
+	if a.overflow.count != 1 || a.objs[far].quad == a.overflow {
+		t.Errorf("object was not moved out of overflow, overflow count = %d", a.overflow.count)
+	}
+	if got := a.Test(Point{65, 65}); got != far {
+		t.Errorf("Test(65,65) = %v, want %v", got, far)
+	}
+
+	a.Delete(wide)
+	if a.overflow.count != 0 || a.Len() != 2 {
+		t.Errorf("after Delete: overflow count = %d, Len() = %d", a.overflow.count, a.Len())
+	}
+	if got := a.Test(Point{-1e9, 45}); got != nil {
+		t.Errorf("Test(-1e9,45) = %v, want nil", got)
+	}
+}
+
+func TestAreaSplitAndCollapse(t *testing.T) {
+	a := NewArea(Point{}, Point{1000, 1000})
+
+	var objs []dummyObj
+	for x := 0.0; x < 1000; x += 50 {
+		for y := 0.0; y < 1000; y += 50 {
+			o := dummyObj{Point{x + 1, y + 1}, Point{x + 10, y + 10}}
+			objs = append(objs, o)
+			a.Add(o.min, o.max, o)
+		}
+	}
+	if a.root.children == nil {
+		t.Fatal("root was not split")
+	}
+	if a.Len() != len(objs) || a.root.count != len(objs) {
+		t.Errorf("Len() = %d, root count = %d, want %d", a.Len(), a.root.count, len(objs))
+	}
+	for _, o := range objs {
+		if got := a.Test(o.min); got != o {
+			t.Errorf("Test(%v) = %v, want %v", o.min, got, o)
+		}
+	}
+
+	for _, o := range objs[1:] {
+		a.Delete(o)
+	}
+	if a.root.children != nil {
+		t.Error("root was not collapsed after deleting objects")
+	}
+	if got := a.Test(objs[0].min); got != objs[0] {
+		t.Errorf("Test(%v) = %v, want %v", objs[0].min, got, objs[0])
+	}
+	if got := a.Test(objs[1].min); got != nil {
+		t.Errorf("Test(%v) = %v, want nil", objs[1].min, got)
+	}
+}
+
+func TestAreaReAdd(t *testing.T) {
+	a := NewArea(Point{}, Point{100, 100})
+	d1 := dummyObj{Point{0, 0}, Point{50, 50}}
+	d2 := dummyObj{Point{10, 10}, Point{60, 60}}
+	a.Add(d1.min, d1.max, d1)
+	a.Add(d2.min, d2.max, d2)
+
+	if got := a.Test(Point{20, 20}); got != d2 {
+		t.Errorf("Test(20,20) = %v, want %v", got, d2)
+	}
+	a.Add(d1.min, d1.max, d1)
+	if got := a.Test(Point{20, 20}); got != d1 {
+		t.Errorf("Test(20,20) after re-adding = %v, want %v", got, d1)
+	}
+	if a.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", a.Len())
+	}
+}
+
+func TestAreaUpdate(t *testing.T) {
+	a := NewArea(Point{}, Point{1000, 1000})
+	under := dummyObj{Point{0, 0}, Point{1000, 1000}}
+	a.Add(under.min, under.max, under)
+
+	// Fill the area so the tree is split, and objects have to move
+	// between quads.
+	var objs []*movingObj
+	for i := 0; i < 40; i++ {
+		o := &movingObj{dummyObj{Point{float64(i * 20), 10}, Point{float64(i*20 + 10), 20}}}
+		objs = append(objs, o)
+		a.Add(o.min, o.max, o)
+	}
+
+	for i, o := range objs {
+		o.min, o.max = Point{float64(i * 20), 600}, Point{float64(i*20 + 10), 610}
+		a.Update(o, o.min, o.max)
+	}
+	// Move one object far outside the original bounds.
+	objs[0].min, objs[0].max = Point{-3000, -3000}, Point{-2990, -2990}
+	a.Update(objs[0], objs[0].min, objs[0].max)
+
+	if a.Len() != len(objs)+1 {
+		t.Errorf("Len() = %d, want %d", a.Len(), len(objs)+1)
+	}
+	for _, o := range objs {
+		if got := a.Test(o.min); got != o {
+			t.Errorf("Test(%v) = %v, want %v", o.min, got, o)
+		}
+	}
+	if got := a.Test(Point{5, 15}); got != under {
+		t.Errorf("Test(5,15) = %v, want %v", got, under)
+	}
+
+	// Updating should not change z-order.
+	a.Update(under, under.min, under.max)
+	if got := a.Test(objs[1].min); got != objs[1] {
+		t.Errorf("Test(%v) = %v, want %v", objs[1].min, got, objs[1])
+	}
+}
+
+// movingObj is a dummyObj which can be mutated while stored in an Area.
+type movingObj struct {
+	dummyObj
+}
+
+func (m *movingObj) HitTest(p Point) bool {
+	return m.dummyObj.HitTest(p)
+}
+
+func TestAreaQueryRect(t *testing.T) {
+	a := NewArea(Point{}, Point{1000, 1000})
+	bottom := dummyObj{Point{0, 0}, Point{500, 500}}
+	mid := dummyObj{Point{100, 100}, Point{200, 200}}
+	top := dummyObj{Point{150, 150}, Point{300, 300}}
+	far := dummyObj{Point{900, 900}, Point{950, 950}}
+	for _, o := range []dummyObj{bottom, mid, top, far} {
+		a.Add(o.min, o.max, o)
+	}
+
+	tcs := []struct {
+		name     string
+		min, max Point
+		mode     QueryMode
+		want     []TestableObj
+	}{
+		{"overlapping", Point{190, 190}, Point{210, 210}, Overlapping, []TestableObj{top, mid, bottom}},
+		{"contained", Point{50, 50}, Point{350, 350}, Contained, []TestableObj{top, mid}},
+		{"contained partial", Point{50, 50}, Point{250, 250}, Contained, []TestableObj{mid}},
+		{"overlapping far", Point{600, 600}, Point{1200, 1200}, Overlapping, []TestableObj{far}},
+		{"empty", Point{600, 100}, Point{700, 200}, Overlapping, []TestableObj{}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got := a.QueryRect(tc.min, tc.max, tc.mode)
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(dummyObj{})); diff != "" {
+				t.Errorf("QueryRect(%v, %v, %v) differs (-want, +got): \n%s", tc.min, tc.max, tc.mode, diff)
+			}
+		})
+	}
+}
+
+func TestAreaQueryPolygon(t *testing.T) {
+	a := NewArea(Point{}, Point{1000, 1000})
+	inside := dummyObj{Point{10, 10}, Point{20, 20}}
+	straddling := dummyObj{Point{40, 40}, Point{80, 60}}
+	crossed := dummyObj{Point{45, -10}, Point{55, 10}}
+	outside := dummyObj{Point{90, 15}, Point{105, 25}}
+	for _, o := range []dummyObj{inside, straddling, crossed, outside} {
+		a.Add(o.min, o.max, o)
+	}
+
+	// A right-angled triangle, with the hypotenuse running from (100,0)
+	// to (0,100).
+	tri := []Point{{0, 0}, {100, 0}, {0, 100}}
+	if diff := cmp.Diff([]TestableObj{crossed, straddling, inside}, a.QueryPolygon(tri, Overlapping), cmp.AllowUnexported(dummyObj{})); diff != "" {
+		t.Errorf("QueryPolygon(Overlapping) differs (-want, +got): \n%s", diff)
+	}
+	if diff := cmp.Diff([]TestableObj{inside}, a.QueryPolygon(tri, Contained), cmp.AllowUnexported(dummyObj{})); diff != "" {
+		t.Errorf("QueryPolygon(Contained) differs (-want, +got): \n%s", diff)
+	}
+
+	// A concave polygon with a notch cut through the middle of an object
+	// should not contain it.
+	notched := []Point{{0, 0}, {100, 0}, {100, 100}, {16, 100}, {15, 5}, {14, 100}, {0, 100}}
+	for _, got := range a.QueryPolygon(notched, Contained) {
+		if got == inside {
+			t.Errorf("QueryPolygon(notched, Contained) included %v", inside)
+		}
+	}
+}
+
+type circleObj struct {
+	center Point
+	radius float64
+}
+
+func (c circleObj) HitTest(p Point) bool { return c.Distance(p) == 0 }
+
+func (c circleObj) Distance(p Point) float64 {
+	return math.Max(0, math.Hypot(p.X-c.center.X, p.Y-c.center.Y)-c.radius)
+}
+
+func TestAreaNearest(t *testing.T) {
+	a := NewArea(Point{}, Point{100, 100})
+	var objs []dummyObj
+	for i := 0; i < 50; i++ {
+		x := float64(i * 20)
+		o := dummyObj{Point{x, 0}, Point{x + 10, 10}}
+		objs = append(objs, o)
+		a.Add(o.min, o.max, o)
+	}
+	circ := circleObj{Point{105, 50}, 10}
+	a.Add(Point{95, 40}, Point{115, 60}, circ)
+
+	got := a.Nearest(Point{105, 5}, 3, math.Inf(1), nil)
+	// Ties are broken in favor of the topmost object.
+	want := []Neighbor{{objs[5], 0}, {objs[6], 15}, {objs[4], 15}}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(dummyObj{})); diff != "" {
+		t.Errorf("Nearest() differs (-want, +got): \n%s", diff)
+	}
+
+	// The circle's bounds are within 30 of the point, but the circle itself is not.
+	got = a.Nearest(Point{105, 25}, 0, 30, func(o TestableObj) bool {
+		_, isCircle := o.(circleObj)
+		return isCircle
+	})
+	if len(got) != 1 || got[0].Obj != circ || got[0].Dist != 15 {
+		t.Errorf("Nearest(circle) = %v, want circle at distance 15", got)
+	}
+	if got := a.Nearest(Point{105, 90}, 0, 20, func(o TestableObj) bool {
+		_, isCircle := o.(circleObj)
+		return isCircle
+	}); len(got) != 0 {
+		t.Errorf("Nearest(circle) = %v, want nothing", got)
+	}
+}
+
+func TestAreaWithinRadius(t *testing.T) {
+	a := NewArea(Point{}, Point{100, 100})
+	near := dummyObj{Point{20, 0}, Point{30, 10}}
+	nearer := dummyObj{Point{0, 0}, Point{10, 10}}
+	far := dummyObj{Point{60, 0}, Point{70, 10}}
+	for _, o := range []dummyObj{near, nearer, far} {
+		a.Add(o.min, o.max, o)
+	}
+
+	got := a.WithinRadius(Point{12, 5}, 10, nil)
+	want := []Neighbor{{nearer, 2}, {near, 8}}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(dummyObj{})); diff != "" {
+		t.Errorf("WithinRadius() differs (-want, +got): \n%s", diff)
+	}
+}
+
+func TestLineDistances(t *testing.T) {
+	tcs := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"segment perpendicular", SegmentDist(Point{5, 3}, Point{0, 0}, Point{10, 0}), 3},
+		{"segment past end", SegmentDist(Point{13, 4}, Point{0, 0}, Point{10, 0}), 5},
+		{"segment degenerate", SegmentDist(Point{3, 4}, Point{0, 0}, Point{0, 0}), 5},
+		{"polyline", PolylineDist(Point{12, 5}, []Point{{0, 0}, {10, 0}, {10, 10}}), 2},
+		{"cubic straight", CubicDist(Point{5, 2}, Point{0, 0}, Point{3, 0}, Point{6, 0}, Point{10, 0}), 2},
+		{"cubic arch", CubicDist(Point{5, 7.5}, Point{0, 0}, Point{0, 10}, Point{10, 10}, Point{10, 0}), 0},
+	}
+	for _, tc := range tcs {
+		if math.Abs(tc.got-tc.want) > 0.01 {
+			t.Errorf("%s: got distance %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestShapes(t *testing.T) {
+	tcs := []struct {
+		name  string
+		shape Shape
+		in    []Point
+		out   []Point
+	}{
+		{
+			name:  "circle",
+			shape: Circle{Center: Point{10, 10}, Radius: 5},
+			in:    []Point{{10, 10}, {14, 13}, {10, 5}},
+			out:   []Point{{14.5, 14.5}, {5.5, 5.5}},
+		},
+		{
+			name:  "diamond",
+			shape: Diamond{Center: Point{0, 0}, Width: 20, Height: 10},
+			in:    []Point{{0, 0}, {9, 0}, {5, 2}},
+			out:   []Point{{8, 4}, {-9, -4}},
+		},
+		{
+			name:  "rounded rect",
+			shape: RoundedRect{Min: Point{0, 0}, Max: Point{20, 10}, Radius: 4},
+			in:    []Point{{10, 5}, {0.5, 5}, {2, 2}, {19, 8}},
+			out:   []Point{{0.5, 0.5}, {19.5, 9.5}, {21, 5}},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewArea(Point{-50, -50}, Point{50, 50})
+			a.AddShape(tc.shape)
+			for _, p := range tc.in {
+				if got := a.Test(p); got != tc.shape {
+					t.Errorf("Test(%v) = %v, want %v", p, got, tc.shape)
+				}
+			}
+			for _, p := range tc.out {
+				if got := a.Test(p); got != nil {
+					t.Errorf("Test(%v) = %v, want nil", p, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAreaTestAll(t *testing.T) {
+	a := NewArea(Point{}, Point{100, 100})
+	node := dummyObj{Point{0, 0}, Point{50, 50}}
+	pad := Circle{Center: Point{50, 25}, Radius: 5}
+	other := dummyObj{Point{60, 0}, Point{90, 50}}
+	a.Add(node.min, node.max, node)
+	a.AddShape(pad)
+	a.Add(other.min, other.max, other)
+
+	if diff := cmp.Diff([]TestableObj{pad, node}, a.TestAll(Point{48, 25}), cmp.AllowUnexported(dummyObj{})); diff != "" {
+		t.Errorf("TestAll(pad on node) differs (-want, +got): \n%s", diff)
+	}
+	if diff := cmp.Diff([]TestableObj{node}, a.TestAll(Point{10, 25}), cmp.AllowUnexported(dummyObj{})); diff != "" {
+		t.Errorf("TestAll(node) differs (-want, +got): \n%s", diff)
+	}
+	// Objects whose bounds contain the point but which aren't hit are excluded.
+	if got := a.TestAll(Point{54.5, 29.5}); len(got) != 0 {
+		t.Errorf("TestAll(outside pad) = %v, want nothing", got)
+	}
+}
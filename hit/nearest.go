@@ -0,0 +1,134 @@
+package hit
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// Distancer can be implemented by objects to provide a more precise distance
+// than the distance to their bounds. The returned distance must never be less
+// than the distance from the point to the bounds of the object.
+type Distancer interface {
+	Distance(Point) float64
+}
+
+// Neighbor describes an object found by a proximity query.
+type Neighbor struct {
+	Obj  TestableObj
+	Dist float64
+}
+
+// rectDist returns the distance from the point to the rectangle, which is
+// zero if the point is inside it.
+func rectDist(p, min, max Point) float64 {
+	dx := math.Max(0, math.Max(min.X-p.X, p.X-max.X))
+	dy := math.Max(0, math.Max(min.Y-p.Y, p.Y-max.Y))
+	return math.Hypot(dx, dy)
+}
+
+func (e *entry) dist(p Point) float64 {
+	if d, ok := e.obj.(Distancer); ok {
+		return d.Distance(p)
+	}
+	return rectDist(p, e.min, e.max)
+}
+
+// candidate is an item in the queue of a best-first search. Exactly one of
+// q or e is set.
+type candidate struct {
+	dist float64
+	q    *quad
+	e    *entry
+}
+
+type candidateQueue []candidate
+
+func (cq candidateQueue) Len() int { return len(cq) }
+func (cq candidateQueue) Less(i, j int) bool {
+	if cq[i].dist != cq[j].dist {
+		return cq[i].dist < cq[j].dist
+	}
+	// Break ties in favor of objects, and then the topmost object.
+	if (cq[i].e != nil) != (cq[j].e != nil) {
+		return cq[i].e != nil
+	}
+	return cq[i].e != nil && cq[i].e.seq > cq[j].e.seq
+}
+func (cq candidateQueue) Swap(i, j int)       { cq[i], cq[j] = cq[j], cq[i] }
+func (cq *candidateQueue) Push(x interface{}) { *cq = append(*cq, x.(candidate)) }
+func (cq *candidateQueue) Pop() interface{} {
+	old := *cq
+	c := old[len(old)-1]
+	*cq = old[:len(old)-1]
+	return c
+}
+
+// Nearest returns up to k objects closest to the given point, closest first.
+// Objects further than maxDist are not returned. If filter is non-nil, only
+// objects for which it returns true are considered. A k of zero or less
+// places no limit on the number of objects returned.
+func (a *Area) Nearest(p Point, k int, maxDist float64, filter func(TestableObj) bool) []Neighbor {
+	var (
+		out []Neighbor
+		cq  = candidateQueue{{dist: rectDist(p, a.root.min, a.root.max), q: a.root}}
+	)
+	if a.overflow.count > 0 {
+		cq = append(cq, candidate{q: a.overflow})
+		heap.Init(&cq)
+	}
+
+	for cq.Len() > 0 && (k <= 0 || len(out) < k) {
+		c := heap.Pop(&cq).(candidate)
+		if c.dist > maxDist {
+			break
+		}
+		if c.e != nil {
+			out = append(out, Neighbor{Obj: c.e.obj, Dist: c.dist})
+			continue
+		}
+
+		for _, e := range c.q.entries {
+			if filter != nil && !filter(e.obj) {
+				continue
+			}
+			if d := e.dist(p); d <= maxDist {
+				heap.Push(&cq, candidate{dist: d, e: e})
+			}
+		}
+		if c.q.children != nil {
+			for i := range c.q.children {
+				child := &c.q.children[i]
+				if child.count == 0 {
+					continue
+				}
+				if d := rectDist(p, child.min, child.max); d <= maxDist {
+					heap.Push(&cq, candidate{dist: d, q: child})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// WithinRadius returns all objects within the given distance of the point,
+// closest first. If filter is non-nil, only objects for which it returns
+// true are considered.
+func (a *Area) WithinRadius(p Point, r float64, filter func(TestableObj) bool) []Neighbor {
+	var matches []candidate
+	a.visitRect(Point{X: p.X - r, Y: p.Y - r}, Point{X: p.X + r, Y: p.Y + r}, func(e *entry) {
+		if filter != nil && !filter(e.obj) {
+			return
+		}
+		if d := e.dist(p); d <= r {
+			matches = append(matches, candidate{dist: d, e: e})
+		}
+	})
+	sort.Sort(candidateQueue(matches))
+
+	out := make([]Neighbor, len(matches))
+	for i, c := range matches {
+		out[i] = Neighbor{Obj: c.e.obj, Dist: c.dist}
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+package hit
+
+import "sort"
+
+// QueryMode controls which objects are matched by region queries.
+type QueryMode uint8
+
+// Valid QueryMode values.
+const (
+	// Overlapping matches objects with bounds which intersect the region.
+	Overlapping QueryMode = iota
+	// Contained matches objects with bounds entirely inside the region.
+	Contained
+)
+
+func overlaps(aMin, aMax, bMin, bMax Point) bool {
+	return aMin.X <= bMax.X && aMax.X >= bMin.X && aMin.Y <= bMax.Y && aMax.Y >= bMin.Y
+}
+
+// visitRect invokes fn for every entry with bounds overlapping the rectangle.
+func (q *quad) visitRect(min, max Point, fn func(*entry)) {
+	if q.count == 0 || !overlaps(q.min, q.max, min, max) {
+		return
+	}
+	for _, e := range q.entries {
+		if overlaps(e.min, e.max, min, max) {
+			fn(e)
+		}
+	}
+	if q.children != nil {
+		for i := range q.children {
+			q.children[i].visitRect(min, max, fn)
+		}
+	}
+}
+
+// byZ returns the objects of the given entries, topmost first.
+func byZ(entries []*entry) []TestableObj {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq > entries[j].seq
+	})
+	out := make([]TestableObj, len(entries))
+	for i, e := range entries {
+		out[i] = e.obj
+	}
+	return out
+}
+
+// QueryRect returns all objects matching the given rectangle, topmost
+// first. Objects are matched based on their bounds.
+func (a *Area) QueryRect(min, max Point, mode QueryMode) []TestableObj {
+	var matches []*entry
+	a.visitRect(min, max, func(e *entry) {
+		if mode == Overlapping || (e.min.X >= min.X && e.min.Y >= min.Y && e.max.X <= max.X && e.max.Y <= max.Y) {
+			matches = append(matches, e)
+		}
+	})
+	return byZ(matches)
+}
+
+// QueryPolygon returns all objects matching the polygon described by the
+// given points, topmost first. Objects are matched based on their bounds.
+func (a *Area) QueryPolygon(points []Point, mode QueryMode) []TestableObj {
+	if len(points) < 3 {
+		return nil
+	}
+	min, max := points[0], points[0]
+	for _, p := range points[1:] {
+		if p.X < min.X {
+			min.X = p.X
+		}
+		if p.Y < min.Y {
+			min.Y = p.Y
+		}
+		if p.X > max.X {
+			max.X = p.X
+		}
+		if p.Y > max.Y {
+			max.Y = p.Y
+		}
+	}
+
+	var matches []*entry
+	a.visitRect(min, max, func(e *entry) {
+		if polygonMatchesRect(points, e.min, e.max, mode) {
+			matches = append(matches, e)
+		}
+	})
+	return byZ(matches)
+}
+
+// pointInPolygon returns true if the point lies inside the polygon, using
+// the even-odd rule.
+func pointInPolygon(p Point, poly []Point) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func cross(o, a, b Point) float64 {
+	return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+}
+
+// segmentsCross returns true if the segments a1-a2 and b1-b2 cross each
+// other at a single point strictly inside both segments.
+func segmentsCross(a1, a2, b1, b2 Point) bool {
+	d1, d2 := cross(b1, b2, a1), cross(b1, b2, a2)
+	d3, d4 := cross(a1, a2, b1), cross(a1, a2, b2)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+func polygonMatchesRect(poly []Point, min, max Point, mode QueryMode) bool {
+	corners := [4]Point{min, {X: max.X, Y: min.Y}, max, {X: min.X, Y: max.Y}}
+
+	var cornersInside int
+	for _, c := range corners {
+		if pointInPolygon(c, poly) {
+			cornersInside++
+		}
+	}
+	if mode == Overlapping && cornersInside > 0 {
+		return true
+	}
+	if mode == Contained && cornersInside < len(corners) {
+		return false
+	}
+
+	// Either the polygon crosses the rectangle, or the rectangle contains
+	// part of the polygon without containing a corner.
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		if p := poly[i]; p.X > min.X && p.X < max.X && p.Y > min.Y && p.Y < max.Y {
+			return mode == Overlapping
+		}
+		for k := range corners {
+			if segmentsCross(poly[j], poly[i], corners[k], corners[(k+1)%len(corners)]) {
+				return mode == Overlapping
+			}
+		}
+	}
+	return mode == Contained
+}
@@ -0,0 +1,139 @@
+package hit
+
+import "math"
+
+// bezierSteps is the number of line segments a cubic Bezier curve is
+// flattened into when computing distances.
+const bezierSteps = 32
+
+// SegmentDist returns the distance from the point to the line segment a-b.
+func SegmentDist(p, a, b Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lenSq := dx*dx + dy*dy
+	if lenSq == 0 {
+		return math.Hypot(p.X-a.X, p.Y-a.Y)
+	}
+	t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / lenSq
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy))
+}
+
+// PolylineDist returns the distance from the point to the polyline passing
+// through the given points.
+func PolylineDist(p Point, pts []Point) float64 {
+	switch len(pts) {
+	case 0:
+		return math.Inf(1)
+	case 1:
+		return math.Hypot(p.X-pts[0].X, p.Y-pts[0].Y)
+	}
+	best := math.Inf(1)
+	for i := 1; i < len(pts); i++ {
+		best = math.Min(best, SegmentDist(p, pts[i-1], pts[i]))
+	}
+	return best
+}
+
+// CubicDist returns the approximate distance from the point to the cubic
+// Bezier curve from start to end, with control points c1 and c2.
+func CubicDist(p, start, c1, c2, end Point) float64 {
+	best, prev := math.Inf(1), start
+	for i := 1; i <= bezierSteps; i++ {
+		t := float64(i) / bezierSteps
+		mt := 1 - t
+		pt := Point{
+			X: mt*mt*mt*start.X + 3*mt*mt*t*c1.X + 3*mt*t*t*c2.X + t*t*t*end.X,
+			Y: mt*mt*mt*start.Y + 3*mt*mt*t*c1.Y + 3*mt*t*t*c2.Y + t*t*t*end.Y,
+		}
+		best = math.Min(best, SegmentDist(p, prev, pt))
+		prev = pt
+	}
+	return best
+}
+
+// Shape describes a region which can be precisely hit tested.
+type Shape interface {
+	TestableObj
+	// Bounds returns the bounding box of the shape.
+	Bounds() (min, max Point)
+}
+
+// AddShape inserts the shape into the hit testing area, on top of all
+// existing objects.
+func (a *Area) AddShape(s Shape) {
+	min, max := s.Bounds()
+	a.Add(min, max, s)
+}
+
+// Circle is a Shape describing a circle.
+type Circle struct {
+	Center Point
+	Radius float64
+}
+
+// Bounds implements Shape.
+func (c Circle) Bounds() (Point, Point) {
+	return Point{X: c.Center.X - c.Radius, Y: c.Center.Y - c.Radius},
+		Point{X: c.Center.X + c.Radius, Y: c.Center.Y + c.Radius}
+}
+
+// HitTest returns true if the point is within the circle.
+func (c Circle) HitTest(p Point) bool {
+	return math.Hypot(p.X-c.Center.X, p.Y-c.Center.Y) <= c.Radius
+}
+
+// Distance returns the distance from the point to the edge of the circle,
+// or zero if the point is inside it.
+func (c Circle) Distance(p Point) float64 {
+	return math.Max(0, math.Hypot(p.X-c.Center.X, p.Y-c.Center.Y)-c.Radius)
+}
+
+// Diamond is a Shape describing a rhombus with its corners at the middle
+// of each side of its bounding box.
+type Diamond struct {
+	Center        Point
+	Width, Height float64
+}
+
+// Bounds implements Shape.
+func (d Diamond) Bounds() (Point, Point) {
+	return Point{X: d.Center.X - d.Width/2, Y: d.Center.Y - d.Height/2},
+		Point{X: d.Center.X + d.Width/2, Y: d.Center.Y + d.Height/2}
+}
+
+// HitTest returns true if the point is within the diamond.
+func (d Diamond) HitTest(p Point) bool {
+	if d.Width <= 0 || d.Height <= 0 {
+		return false
+	}
+	return math.Abs(p.X-d.Center.X)/(d.Width/2)+math.Abs(p.Y-d.Center.Y)/(d.Height/2) <= 1
+}
+
+// RoundedRect is a Shape describing a rectangle with rounded corners.
+type RoundedRect struct {
+	Min, Max Point
+	Radius   float64
+}
+
+// Bounds implements Shape.
+func (r RoundedRect) Bounds() (Point, Point) {
+	return r.Min, r.Max
+}
+
+// HitTest returns true if the point is within the rectangle, excluding the
+// area cut away by the rounded corners.
+func (r RoundedRect) HitTest(p Point) bool {
+	if p.X < r.Min.X || p.Y < r.Min.Y || p.X > r.Max.X || p.Y > r.Max.Y {
+		return false
+	}
+	rad := math.Min(r.Radius, math.Min(r.Max.X-r.Min.X, r.Max.Y-r.Min.Y)/2)
+	if rad <= 0 {
+		return true
+	}
+
+	// Find the center of the nearest corner arc, and check the point is
+	// within the arc if it lies in the corner region.
+	cx := math.Max(r.Min.X+rad, math.Min(p.X, r.Max.X-rad))
+	cy := math.Max(r.Min.Y+rad, math.Min(p.Y, r.Max.Y-rad))
+	return math.Hypot(p.X-cx, p.Y-cy) <= rad
+}